@@ -0,0 +1,243 @@
+package excel_template
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SheetData 按工作表名称绑定填充数据，例如汇总表和明细表使用不同的数据
+type SheetData struct {
+	// Global 未在 Sheets 中出现的工作表使用的数据，为 nil 时这些工作表不填充数据
+	Global any
+	// Sheets 工作表名称到填充数据的映射，数据格式与 Render 的参数相同
+	Sheets map[string]any
+}
+
+// asSheetData 判断渲染数据是否为按工作表绑定的数据
+func asSheetData(data any) (*SheetData, bool) {
+	switch v := data.(type) {
+	case SheetData:
+		return &v, true
+	case *SheetData:
+		return v, v != nil
+	}
+	return nil, false
+}
+
+// toFillData 将渲染数据转换为工作表的 FillData，切片作为 ListField 对应的列表
+func (et *ExcelTemplate) toFillData(data any) map[string]any {
+	data = normalizeData(data)
+	if list, ok := data.([]map[string]any); ok {
+		return map[string]any{et.ListField: list}
+	}
+	fillData, _ := data.(map[string]any)
+	return fillData
+}
+
+// mergeFillData 合并填充数据，overlay 中的字段覆盖 base 中的同名字段
+func mergeFillData(base map[string]any, overlay map[string]any) map[string]any {
+	result := make(map[string]any, len(base)+len(overlay))
+	maps.Copy(result, base)
+	maps.Copy(result, overlay)
+	return result
+}
+
+// excelTagName 结构体字段标签，例如 `excel:"订单号"`，未设置时使用 json 标签
+const excelTagName = "excel"
+
+// normalizeData 将结构体、指针、map 及其切片统一转换为 map[string]any 和 []map[string]any，
+// 使数据字段、Go 模板和公式变量都可以按字段名取值。time.Time 等值类型保持不变
+func normalizeData(data any) any {
+	result, _ := normalizeAny(data)
+	return result
+}
+
+// normalizeAny 转换 value，第二个返回值表示是否生成了新的值。
+// JSON 解码得到的 map[string]any、[]any 和标量只在包含需要转换的值时复制，避免大量数据被逐个重建
+func normalizeAny(value any) (any, bool) {
+	switch v := value.(type) {
+	case nil, string, bool, float64, int, int64, time.Time, []byte, []map[string]any:
+		return v, false
+	case map[string]any:
+		var result map[string]any
+		for key, item := range v {
+			normalized, changed := normalizeAny(item)
+			if !changed {
+				continue
+			}
+			if result == nil {
+				result = maps.Clone(v)
+			}
+			result[key] = normalized
+		}
+		if result == nil {
+			return v, false
+		}
+		return result, true
+	case []any:
+		items, copied := v, false
+		allMaps := len(v) > 0
+		for i, item := range v {
+			normalized, changed := normalizeAny(item)
+			if changed {
+				if !copied {
+					items, copied = slices.Clone(v), true
+				}
+				items[i] = normalized
+			}
+			if _, ok := normalized.(map[string]any); !ok {
+				allMaps = false
+			}
+		}
+		// 元素都是对象时转换为列表数据，对象本身不复制
+		if allMaps {
+			list := make([]map[string]any, len(items))
+			for i, item := range items {
+				list[i] = item.(map[string]any)
+			}
+			return list, true
+		}
+		return items, copied
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+		return normalizeValue(reflect.ValueOf(value)), true
+	}
+	return value, false
+}
+
+func normalizeValue(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.CanInterface() {
+		return nil
+	}
+	switch value := v.Interface().(type) {
+	case time.Time, []byte:
+		return value
+	case []map[string]any:
+		// 已经是列表数据，避免大列表被逐行复制
+		return value
+	case map[string]any, []any:
+		result, _ := normalizeAny(value)
+		return result
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		result := make(map[string]any, v.NumField())
+		structToMap(v, result)
+		return result
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		result := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = normalizeValue(iter.Value())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		// 元素为标量的切片（如 []string）保持不变
+		if !needsNormalize(v.Type().Elem()) {
+			return v.Interface()
+		}
+		items := make([]any, v.Len())
+		allMaps := v.Len() > 0
+		for i := range v.Len() {
+			items[i] = normalizeValue(v.Index(i))
+			if _, ok := items[i].(map[string]any); !ok {
+				allMaps = false
+			}
+		}
+		// 元素都是对象时转换为列表数据
+		if allMaps {
+			list := make([]map[string]any, len(items))
+			for i, item := range items {
+				list[i] = item.(map[string]any)
+			}
+			return list
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+// needsNormalize 切片元素类型是否可能需要转换：结构体、map、切片、指针和接口，time.Time 除外
+func needsNormalize(t reflect.Type) bool {
+	if t == reflect.TypeFor[time.Time]() {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// structToMap 将结构体字段写入 result，匿名嵌入的结构体字段会被展开
+func structToMap(v reflect.Value, result map[string]any) {
+	t := v.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		fieldValue := v.Field(i)
+		if field.Anonymous && name == "" {
+			for fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					break
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				structToMap(fieldValue, result)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			name = field.Name
+		}
+		result[name] = normalizeValue(fieldValue)
+	}
+}
+
+// fieldName 返回结构体字段对应的数据字段名，匿名嵌入且未设置标签时返回空字符串
+func fieldName(field reflect.StructField) (string, bool) {
+	// 未导出的匿名嵌入结构体仍需展开其导出字段
+	if !field.IsExported() && !field.Anonymous {
+		return "", false
+	}
+	for _, tagName := range []string{excelTagName, "json"} {
+		tag, ok := field.Tag.Lookup(tagName)
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	if field.Anonymous {
+		return "", true
+	}
+	return field.Name, true
+}
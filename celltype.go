@@ -0,0 +1,210 @@
+package excel_template
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// numFmtKind 单元格数字格式的类别，决定模板输出写入时的类型
+type numFmtKind int
+
+const (
+	// 常规格式，按字段的原始值推断类型
+	numFmtGeneral numFmtKind = iota
+	// 文本格式，始终写入文本
+	numFmtText
+	// 数字、货币、百分比等格式，文本按数字解析
+	numFmtNumber
+	// 日期时间格式，文本按日期解析
+	numFmtDate
+)
+
+// 内置的日期时间格式 ID，包括中日韩语言的日期格式
+var builtInDateNumFmts = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true,
+	50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true, 57: true, 58: true,
+}
+
+// numFmtLiteralRegexp 匹配自定义格式中不参与判断的部分：引号中的文字、转义字符和颜色、条件等方括号
+var numFmtLiteralRegexp = regexp.MustCompile(`"[^"]*"|\\.|_.|\*.|\[(?i:[^hms\]][^\]]*)\]`)
+
+// styleNumFmtKind 返回样式的数字格式类别
+func styleNumFmtKind(style *excelize.Style) numFmtKind {
+	if style == nil {
+		return numFmtGeneral
+	}
+	if style.CustomNumFmt != nil {
+		return customNumFmtKind(*style.CustomNumFmt)
+	}
+	switch {
+	case style.NumFmt == 0:
+		return numFmtGeneral
+	case style.NumFmt == 49:
+		return numFmtText
+	case builtInDateNumFmts[style.NumFmt]:
+		return numFmtDate
+	}
+	return numFmtNumber
+}
+
+// customNumFmtKind 按格式代码判断自定义格式的类别
+func customNumFmtKind(code string) numFmtKind {
+	switch strings.ToLower(code) {
+	case "", "general":
+		return numFmtGeneral
+	case "@":
+		return numFmtText
+	}
+	code = strings.ToLower(numFmtLiteralRegexp.ReplaceAllString(code, ""))
+	if strings.ContainsAny(code, "ymdhs") {
+		return numFmtDate
+	}
+	return numFmtNumber
+}
+
+// singleFieldRegexp 匹配只引用一个字段的模板，如 {{.总金额}}、{{ .客户.名称 }}
+var singleFieldRegexp = regexp.MustCompile(`^{{-?\s*((?:\.[\p{L}\p{N}_]+)+)\s*-?}}$`)
+
+// fieldValue 按字段路径读取数据中的原始值
+func fieldValue(data any, path string) (any, bool) {
+	value := data
+	for _, name := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// templateCellValue 将模板的渲染结果转换为单元格的值或公式：
+//   - 以 = 开头的模板作为公式写入
+//   - 只引用一个字段的模板使用字段的原始值，数字、布尔值和时间不再写成文本
+//   - 单元格为数字或日期格式时，文本按格式解析为数字或日期，解析失败时保留文本
+//   - 单元格为文本格式时始终写入文本
+//
+// 时间按 timeZone 转换后写入，timeZone 为 nil 时使用时间本身的时区
+func templateCellValue(tmplStr string, output string, data any, style *excelize.Style, timeZone *time.Location) (any, string) {
+	kind := styleNumFmtKind(style)
+	if kind == numFmtText {
+		return output, ""
+	}
+	if strings.HasPrefix(tmplStr, "=") {
+		return nil, strings.TrimPrefix(output, "=")
+	}
+	var value any = output
+	if match := singleFieldRegexp.FindStringSubmatch(tmplStr); match != nil {
+		if raw, ok := fieldValue(data, match[1]); ok && isTypedValue(raw) {
+			value = raw
+		}
+	}
+	return convertCellValue(value, kind, timeZone), ""
+}
+
+// isTypedValue 是否为写入单元格时可以保留类型的值
+func isTypedValue(value any) bool {
+	switch value.(type) {
+	case bool, time.Time:
+		return true
+	}
+	if value == nil {
+		return false
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// dateLayouts 日期格式的单元格解析文本时依次尝试的格式
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"15:04:05",
+}
+
+// convertCellValue 按单元格的数字格式转换值，日期格式的单元格中时间写为序列号以保留模板中的格式
+func convertCellValue(value any, kind numFmtKind, timeZone *time.Location) any {
+	str, isStr := value.(string)
+	switch kind {
+	case numFmtNumber:
+		if isStr {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil {
+				return number
+			}
+		}
+	case numFmtDate:
+		if isStr {
+			for _, layout := range dateLayouts {
+				if t, err := parseTime(layout, str, timeZone); err == nil {
+					value = t
+					break
+				}
+			}
+		}
+		if t, ok := value.(time.Time); ok {
+			return excelSerialTime(cellTime(t, timeZone))
+		}
+	}
+	return value
+}
+
+// parseTime 按 layout 解析文本中的时间，文本中没有时区时使用 timeZone，timeZone 为 nil 时使用 UTC
+func parseTime(layout string, str string, timeZone *time.Location) (time.Time, error) {
+	if timeZone == nil {
+		timeZone = time.UTC
+	}
+	return time.ParseInLocation(layout, strings.TrimSpace(str), timeZone)
+}
+
+// cellTime 返回时间在 timeZone 中的钟面时间，时区为 UTC，写入单元格时不再受时区影响。
+// Excel 的日期没有时区，excelize 按 UTC 计算序列号
+func cellTime(t time.Time, timeZone *time.Location) time.Time {
+	if timeZone != nil {
+		t = t.In(timeZone)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// timeNumFmt 常规格式的单元格写入时间时使用的内置格式，没有时分秒时只显示日期
+func timeNumFmt(t time.Time) int {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return 14
+	}
+	return 22
+}
+
+// excelSerialTime 将时间转换为 Excel 的日期序列号，使用时间的钟面时间
+func excelSerialTime(t time.Time) float64 {
+	// 只有时间没有日期时为一天中的比例
+	if t.Year() == 0 {
+		return float64(t.Hour()*3600+t.Minute()*60+t.Second()) / 86400
+	}
+	wall := cellTime(t, nil)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := float64(wall.Unix()-epoch.Unix()) / 86400
+	days += float64(wall.Nanosecond()) / float64(24*time.Hour)
+	// Excel 中 1900-03-01 之前的序列号少 1（1900-02-29 不存在）
+	if days < 61 {
+		days--
+	}
+	return days
+}
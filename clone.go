@@ -0,0 +1,171 @@
+package excel_template
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// invalidSheetNameChars Excel 工作表名称中不允许出现的字符
+const invalidSheetNameChars = `[]:*?/\`
+
+// CloneSheet 按列表元素复制工作表，来自模板中的“复制工作表”配置行。
+// 每个元素复制一份模板工作表并使用元素数据渲染，渲染完成后删除模板工作表
+type CloneSheet struct {
+	// 列表字段
+	ListField string
+	// 工作表名称模板，例如 {{.客户名称}}，为空时使用模板工作表名称加序号
+	SheetName string
+}
+
+// cloneSheet 为列表中的每个元素复制模板工作表并渲染，复制出的工作表位于模板工作表的位置。
+// 列表为空时删除模板工作表，工作簿中只有这一个工作表时保留，并使用整体数据渲染一次
+func (et *ExcelTemplate) cloneSheet(ctx context.Context, sheet string) error {
+	cache := et.SheetCache[sheet]
+	list := getList(cache.FillData, cache.Clone.ListField)
+	sheetIndex, err := et.File.GetSheetIndex(sheet)
+	if err != nil {
+		return fmt.Errorf("cloneSheet: failed to get sheet index [sheet=%s]: %w", sheet, err)
+	}
+	isActive := et.File.GetActiveSheetIndex() == sheetIndex
+	pictureCells, err := et.File.GetPictureCells(sheet)
+	if err != nil {
+		return fmt.Errorf("cloneSheet: failed to get picture cells [sheet=%s]: %w", sheet, err)
+	}
+	pageLayout, err := et.File.GetPageLayout(sheet)
+	if err != nil {
+		return fmt.Errorf("cloneSheet: failed to get page layout [sheet=%s]: %w", sheet, err)
+	}
+
+	sheetNames := et.File.GetSheetList()
+	clonedSheets := make([]string, 0, len(list))
+	for i, item := range list {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("cloneSheet: render canceled [sheet=%s, item=%d]: %w", sheet, i, err)
+		}
+		// 元素数据优先，工作表中同样可以使用整体数据
+		itemData := mergeFillData(cache.FillData, item)
+
+		name := ""
+		if cache.Clone.SheetName != "" {
+			name, err = RenderTemplate(cache.Clone.SheetName, itemData, et.FuncMap)
+			if err != nil {
+				return fmt.Errorf("cloneSheet: failed to render sheet name [sheet=%s, item=%d]: %w", sheet, i, err)
+			}
+		}
+		name = sanitizeSheetName(name)
+		if name == "" {
+			name = sanitizeSheetName(fmt.Sprintf("%s%d", sheet, i+1))
+		}
+		name = uniqueSheetName(name, sheetNames)
+		sheetNames = append(sheetNames, name)
+
+		index, err := et.File.NewSheet(name)
+		if err != nil {
+			return fmt.Errorf("cloneSheet: failed to create sheet [sheet=%s, name=%s]: %w", sheet, name, err)
+		}
+		err = et.File.CopySheet(sheetIndex, index)
+		if err != nil {
+			return fmt.Errorf("cloneSheet: failed to copy sheet [sheet=%s, name=%s]: %w", sheet, name, err)
+		}
+		// CopySheet 不复制图片和页面设置
+		for _, cellName := range pictureCells {
+			pictures, err := et.File.GetPictures(sheet, cellName)
+			if err != nil {
+				return fmt.Errorf("cloneSheet: failed to get pictures [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+			}
+			for _, picture := range pictures {
+				err = et.File.AddPictureFromBytes(name, cellName, &picture)
+				if err != nil {
+					return fmt.Errorf("cloneSheet: failed to add picture [sheet=%s, cell=%s]: %w", name, cellName, err)
+				}
+			}
+		}
+		et.File.SetPageLayout(name, &pageLayout)
+		// 新工作表位于末尾，移动到模板工作表之前，删除模板后即为模板原来的位置
+		err = et.File.MoveSheet(name, sheet)
+		if err != nil {
+			return fmt.Errorf("cloneSheet: failed to move sheet [sheet=%s, name=%s]: %w", sheet, name, err)
+		}
+		sheetIndex, _ = et.File.GetSheetIndex(sheet)
+
+		cloneCache := *cache
+		cloneCache.Clone = nil
+		cloneCache.FillData = itemData
+		et.SheetCache[name] = &cloneCache
+		err = et.processSheet(ctx, name)
+		if err != nil {
+			return fmt.Errorf("cloneSheet: failed to process sheet [sheet=%s, name=%s]: %w", sheet, name, err)
+		}
+		clonedSheets = append(clonedSheets, name)
+	}
+
+	err = et.File.DeleteSheet(sheet)
+	if err != nil {
+		return fmt.Errorf("cloneSheet: failed to delete template sheet [sheet=%s]: %w", sheet, err)
+	}
+	if index, _ := et.File.GetSheetIndex(sheet); index == -1 {
+		delete(et.SheetCache, sheet)
+	} else {
+		// 工作簿中不能没有工作表，保留的模板工作表已删除配置行，需要渲染其中的模板单元格
+		cache.Clone = nil
+		err = et.processSheet(ctx, sheet)
+		if err != nil {
+			return fmt.Errorf("cloneSheet: failed to process template sheet [sheet=%s]: %w", sheet, err)
+		}
+	}
+	if isActive && len(clonedSheets) > 0 {
+		index, _ := et.File.GetSheetIndex(clonedSheets[0])
+		et.File.SetActiveSheet(index)
+	}
+	return nil
+}
+
+// sanitizeSheetName 按 Excel 的规则处理工作表名称：替换不允许的字符，去掉首尾的单引号，最长31个字符
+func sanitizeSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(invalidSheetNameChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	return strings.TrimRight(truncateSheetName(name, excelize.MaxSheetNameLength), "'")
+}
+
+// truncateSheetName 按 UTF-16 编码长度截断工作表名称，与 Excel 计算名称长度的方式一致
+func truncateSheetName(name string, length int) string {
+	count := 0
+	for i, r := range name {
+		count += utf16.RuneLen(r)
+		if count > length {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// uniqueSheetName 工作表名称不区分大小写，与已有名称重复时追加 (2)、(3) 等序号
+func uniqueSheetName(name string, sheetNames []string) string {
+	exists := func(name string) bool {
+		for _, sheetName := range sheetNames {
+			if strings.EqualFold(sheetName, name) {
+				return true
+			}
+		}
+		return false
+	}
+	if !exists(name) {
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate := truncateSheetName(name, excelize.MaxSheetNameLength-len(suffix)) + suffix
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,490 @@
+// excel-template 命令行工具
+//
+//	excel-template render --template template.xlsx --data data.json --out output.xlsx
+//	excel-template lint --template template.xlsx
+//	excel-template schema --template template.xlsx
+//	excel-template inspect --template template.xlsx
+//	excel-template serve --dir templates --addr :8080
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	excel_template "github.com/mzzya/excel_template"
+	"github.com/mzzya/excel_template/server"
+	"github.com/xuri/excelize/v2"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errUsage 参数错误，退出码为 2
+var errUsage = errors.New("invalid arguments")
+
+// run 执行子命令并返回退出码
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	var err error
+	code := 0
+	switch args[0] {
+	case "render":
+		err = render(args[1:], stdin, stderr)
+	case "lint":
+		code, err = lint(args[1:], stdout, stderr)
+	case "schema":
+		err = schema(args[1:], stdout, stderr)
+	case "inspect":
+		err = inspect(args[1:], stdout, stderr)
+	case "serve":
+		err = serve(args[1:], stderr)
+	case "help", "-h", "--help":
+		usage(stdout)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if errors.Is(err, errUsage) {
+		if err != errUsage {
+			fmt.Fprintln(stderr, err)
+		}
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return code
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, `usage: excel-template <command> [flags]
+
+commands:
+  render    render a template with JSON or NDJSON data
+  lint      check template config and report problems
+  schema    print the JSON Schema of the data a template expects
+  inspect   print the tables, sections and template cells of a template
+  serve     run the HTTP rendering service for a template directory
+
+run "excel-template <command> -h" for the flags of a command`)
+}
+
+// funcMap 命令行渲染时模板中可以使用的函数
+var funcMap = template.FuncMap{
+	"toUpper": strings.ToUpper,
+	"toLower": strings.ToLower,
+	"trim":    strings.TrimSpace,
+}
+
+// templateFlags 各子命令共用的模板参数
+type templateFlags struct {
+	path      string
+	language  string
+	listField string
+}
+
+func (tf *templateFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&tf.path, "template", "", "template xlsx path (required)")
+	fs.StringVar(&tf.language, "language", "", "config keyword language (en, zh or a registered language), detected per sheet when empty")
+	fs.StringVar(&tf.listField, "list-field", "", `list field used by tables without a "List" row (default "table")`)
+}
+
+// open 打开模板并应用参数
+func (tf *templateFlags) open() (*excel_template.ExcelTemplate, error) {
+	if tf.path == "" {
+		return nil, fmt.Errorf("%w: --template is required", errUsage)
+	}
+	et, err := excel_template.OpenFile(tf.path)
+	if err != nil {
+		return nil, err
+	}
+	et.Language = tf.language
+	et.FuncMap = funcMap
+	if tf.listField != "" {
+		et.ListField = tf.listField
+	}
+	return et, nil
+}
+
+// newFlagSet 创建子命令参数，解析错误输出到 stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// parseFlags 解析参数，-h 返回 flag.ErrHelp，参数错误返回 errUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return errUsage
+}
+
+// render 渲染模板并保存
+func render(args []string, stdin io.Reader, stderr io.Writer) error {
+	fs := newFlagSet("render", stderr)
+	tf := &templateFlags{}
+	tf.register(fs)
+	dataPath := fs.String("data", "-", `data file path, "-" reads from stdin`)
+	format := fs.String("format", "", `data format "json" or "ndjson", detected from the file extension when empty`)
+	out := fs.String("out", "", "output xlsx path (required)")
+	streaming := fs.Bool("streaming", false, "write data rows with a stream writer for very large lists")
+	collectErrors := fs.Bool("collect-errors", false, "keep rendering after cell errors, save the output and report every error")
+	missingFields := fs.String("missing-fields", "", `missing field policy "error", "blank" or "default", empty keeps the legacy behavior`)
+	timeZone := fs.String("time-zone", "", `time zone for dates written to cells, e.g. "Asia/Shanghai"`)
+	autoFit := fs.Bool("auto-fit", false, "fit column widths and wrapped row heights to the rendered text")
+	maxColumnWidth := fs.Float64("max-column-width", 0, "max column width in characters for --auto-fit, 0 for no limit")
+	conditionalColors := fs.Bool("conditional-colors", false, "write background and font color expressions as Excel conditional formats")
+	orientation := fs.String("orientation", "", `page orientation "portrait" or "landscape"`)
+	paperSize := fs.Int("paper-size", 0, "paper size code, e.g. 9 for A4")
+	fitToWidth := fs.Int("fit-to-width", 0, "number of pages to fit the sheet width to")
+	fitToHeight := fs.Int("fit-to-height", 0, "number of pages to fit the sheet height to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("%w: --out is required", errUsage)
+	}
+	policy, err := parseMissingFieldPolicy(*missingFields)
+	if err != nil {
+		return err
+	}
+	location, err := loadTimeZone(*timeZone)
+	if err != nil {
+		return err
+	}
+
+	data, err := readData(*dataPath, *format, stdin)
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	et, err := tf.open()
+	if err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+	defer et.File.Close()
+	et.Streaming = *streaming
+	et.CollectErrors = *collectErrors
+	et.MissingFields = policy
+	et.TimeZone = location
+	et.ConditionalColors = *conditionalColors
+	if *autoFit {
+		et.AutoFit = &excel_template.AutoFitOptions{MaxColumnWidth: *maxColumnWidth}
+	}
+
+	layout := &excelize.PageLayoutOptions{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "orientation":
+			layout.Orientation = orientation
+		case "paper-size":
+			layout.Size = paperSize
+		case "fit-to-width":
+			layout.FitToWidth = fitToWidth
+		case "fit-to-height":
+			layout.FitToHeight = fitToHeight
+		}
+	})
+	if *layout != (excelize.PageLayoutOptions{}) {
+		et.PageLayoutOptions = layout
+	}
+
+	f, renderErr := et.Render(data)
+	// 收集模式下有单元格错误时仍然保存渲染结果
+	var renderErrs excel_template.RenderErrors
+	if renderErr != nil && !errors.As(renderErr, &renderErrs) {
+		return fmt.Errorf("render: %w", renderErr)
+	}
+	err = f.SaveAs(*out)
+	if err != nil {
+		return fmt.Errorf("render: failed to save [out=%s]: %w", *out, err)
+	}
+	for _, cellErr := range renderErrs {
+		fmt.Fprintln(stderr, cellErr)
+	}
+	if len(renderErrs) > 0 {
+		return fmt.Errorf("render: %d cells failed to render [out=%s]", len(renderErrs), *out)
+	}
+	return nil
+}
+
+// parseMissingFieldPolicy 校验 --missing-fields 参数
+func parseMissingFieldPolicy(value string) (excel_template.MissingFieldPolicy, error) {
+	policy := excel_template.MissingFieldPolicy(value)
+	switch policy {
+	case excel_template.MissingFieldIgnore, excel_template.MissingFieldError, excel_template.MissingFieldBlank, excel_template.MissingFieldDefault:
+		return policy, nil
+	}
+	return "", fmt.Errorf("%w: unknown missing field policy %q", errUsage, value)
+}
+
+// loadTimeZone 加载 --time-zone 参数指定的时区，为空时返回 nil
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return location, nil
+}
+
+// readData 读取 JSON 或 NDJSON 数据，NDJSON 每行一个对象，作为 ListField 对应的列表
+func readData(path string, format string, stdin io.Reader) (any, error) {
+	var r io.Reader = stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open data [path=%s]: %w", path, err)
+		}
+		defer file.Close()
+		r = file
+	}
+	if format == "" {
+		format = "json"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".ndjson" || ext == ".jsonl" {
+			format = "ndjson"
+		}
+	}
+
+	switch format {
+	case "json":
+		var data any
+		err := json.NewDecoder(r).Decode(&data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode json [path=%s]: %w", path, err)
+		}
+		return data, nil
+	case "ndjson":
+		list := make([]map[string]any, 0)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var row map[string]any
+			err := json.Unmarshal(line, &row)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode ndjson [path=%s, line=%d]: %w", path, lineNum, err)
+			}
+			list = append(list, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read ndjson [path=%s]: %w", path, err)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("unknown data format [format=%s]", format)
+}
+
+// lint 检查模板，有问题时返回退出码 1
+func lint(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
+	fs := newFlagSet("lint", stderr)
+	tf := &templateFlags{}
+	tf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return 0, err
+	}
+	et, err := tf.open()
+	if err != nil {
+		return 1, fmt.Errorf("lint: %w", err)
+	}
+	defer et.File.Close()
+	issues := et.Lint()
+	for _, issue := range issues {
+		fmt.Fprintln(stdout, issue)
+	}
+	if len(issues) > 0 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// schema 输出模板数据结构的 JSON Schema
+func schema(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("schema", stderr)
+	tf := &templateFlags{}
+	tf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	et, err := tf.open()
+	if err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	defer et.File.Close()
+	s, err := et.Schema()
+	if err != nil {
+		return fmt.Errorf("schema: %w", err)
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// inspect 输出模板解析后的结构，行号和列号为删除配置行列后的位置
+func inspect(args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := newFlagSet("inspect", stderr)
+	tf := &templateFlags{}
+	tf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	et, err := tf.open()
+	if err != nil {
+		return fmt.Errorf("inspect: %w", err)
+	}
+	defer et.File.Close()
+	err = et.Prepare()
+	if err != nil {
+		return fmt.Errorf("inspect: %w", err)
+	}
+
+	for _, sheet := range et.File.GetSheetList() {
+		cache := et.SheetCache[sheet]
+		fmt.Fprintf(stdout, "sheet %s\n", sheet)
+		if cache.Clone != nil {
+			fmt.Fprintf(stdout, "  clone per %s, name %q\n", cache.Clone.ListField, cache.Clone.SheetName)
+		}
+		for _, hl := range cache.HorizontalLists {
+			kind := "horizontal list"
+			if hl.Dynamic {
+				kind = "dynamic columns"
+			}
+			fmt.Fprintf(stdout, "  %s %s, columns %s\n", kind, hl.ListField, colRange(hl.StartCol, hl.EndCol))
+		}
+		for _, templateCell := range cache.TemplateCells {
+			fmt.Fprintf(stdout, "  cell %s %s\n", templateCell.CellName, templateCell.Template)
+		}
+		for _, block := range cache.Blocks {
+			printBlock(stdout, "  ", et.ListField, block)
+		}
+		for _, section := range cache.Sections {
+			fmt.Fprintf(stdout, "  section %s, rows %d-%d\n", section.ListField, section.StartRowNum, section.EndRowNum)
+			for _, templateCell := range section.TemplateCells {
+				fmt.Fprintf(stdout, "    cell %s %s\n", templateCell.CellName, templateCell.Template)
+			}
+			for _, block := range section.Blocks {
+				printBlock(stdout, "    ", et.ListField, block)
+			}
+		}
+	}
+	return nil
+}
+
+// printBlock 输出列表区域及其列
+func printBlock(w io.Writer, indent string, listField string, block *excel_template.TableBlock) {
+	if block.ListField != "" {
+		listField = block.ListField
+	}
+	fmt.Fprintf(w, "%stable %s, data row %d, template data rows %d\n", indent, listField, block.StartRowNum, block.TemplateDataRows)
+	for _, column := range block.ColumnList {
+		fmt.Fprintf(w, "%s  %s %s <- %s", indent, column.RenderColName, column.Header, column.DataField)
+		if column.BackgroundColorExpr != "" {
+			fmt.Fprintf(w, ", background %s", column.BackgroundColorExpr)
+		}
+		if column.FontColorExpr != "" {
+			fmt.Fprintf(w, ", font %s", column.FontColorExpr)
+		}
+		if column.DefaultValue != nil {
+			fmt.Fprintf(w, ", default %v", column.DefaultValue)
+		}
+		if column.DateLayout != "" {
+			fmt.Fprintf(w, ", date layout %s", column.DateLayout)
+		}
+		if column.NumberFormat != "" {
+			fmt.Fprintf(w, ", number format %s", column.NumberFormat)
+		}
+		if column.AutoWidth {
+			fmt.Fprint(w, ", width auto")
+		} else if column.Width > 0 {
+			fmt.Fprintf(w, ", width %v", column.Width)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func colRange(startCol int, endCol int) string {
+	start, _ := excelize.ColumnNumberToName(startCol)
+	end, _ := excelize.ColumnNumberToName(endCol)
+	if start == end {
+		return start
+	}
+	return start + ":" + end
+}
+
+// serve 启动 HTTP 渲染服务
+func serve(args []string, stderr io.Writer) error {
+	fs := newFlagSet("serve", stderr)
+	dir := fs.String("dir", "templates", "template directory, template id is the file name without .xlsx")
+	addr := fs.String("addr", ":8080", "listen address")
+	language := fs.String("language", "", "config keyword language, detected per sheet when empty")
+	listField := fs.String("list-field", "", `list field used by tables without a "List" row (default "table")`)
+	missingFields := fs.String("missing-fields", "", `missing field policy "error", "blank" or "default", empty keeps the legacy behavior`)
+	timeZone := fs.String("time-zone", "", `time zone for dates written to cells, e.g. "Asia/Shanghai"`)
+	maxBody := fs.Int64("max-body", server.DefaultMaxBodyBytes, "max render request body bytes")
+	maxTemplate := fs.Int64("max-template", server.DefaultMaxTemplateBytes, "max uploaded template bytes")
+	maxRows := fs.Int("max-rows", server.DefaultMaxRows, "max total list items in a render request, 0 for no limit")
+	allowUpload := fs.Bool("allow-upload", false, "allow PUT /templates/{id} to overwrite templates in --dir")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	policy, err := parseMissingFieldPolicy(*missingFields)
+	if err != nil {
+		return err
+	}
+	location, err := loadTimeZone(*timeZone)
+	if err != nil {
+		return err
+	}
+
+	registry := server.NewRegistry(*dir)
+	registry.FuncMap = funcMap
+	registry.Language = *language
+	registry.ListField = *listField
+	registry.MissingFields = policy
+	registry.TimeZone = location
+	handler := server.NewHandler(registry)
+	handler.MaxBodyBytes = *maxBody
+	handler.MaxTemplateBytes = *maxTemplate
+	handler.MaxRows = *maxRows
+	handler.AllowUpload = *allowUpload
+	handler.ErrorLog = log.New(stderr, "", log.LstdFlags)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          handler.ErrorLog,
+	}
+	fmt.Fprintf(stderr, "serving templates from %s on %s\n", *dir, *addr)
+	return srv.ListenAndServe()
+}
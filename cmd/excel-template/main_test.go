@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const templatePath = "../../template/template.xlsx"
+
+// runCommand 执行命令并返回退出码和输出
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// newOrder 返回示例模板中一行完整的订单数据
+func newOrder(orderNo string, customer string) map[string]any {
+	return map[string]any{
+		"订单号":  orderNo,
+		"客户名称": customer,
+		"成本中心": "CC001",
+		"公司名称": "宏李四网络技术有限公司",
+		"含税金额": 100.5,
+		"未税金额": 90,
+		"是否签收": "是",
+		"数量":   10,
+		"下单时间": "2025-04-28 10:00:00",
+		"签收时间": "2025-04-29",
+		"条形码":  "",
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{nil, {"unknown"}} {
+		if code, _, stderr := runCommand("", args...); code != 2 || !strings.Contains(stderr, "usage:") {
+			t.Errorf("%v 期望退出码 2 并输出用法，实际 %d %s", args, code, stderr)
+		}
+	}
+	if code, stdout, _ := runCommand("", "help"); code != 0 || !strings.Contains(stdout, "usage:") {
+		t.Errorf("help 期望退出码 0 并输出用法，实际 %d %s", code, stdout)
+	}
+	if code, _, _ := runCommand("", "lint", "-h"); code != 0 {
+		t.Errorf("-h 期望退出码 0，实际 %d", code)
+	}
+}
+
+func TestTemplateFlagErrors(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.xlsx")
+	for _, command := range []string{"lint", "schema", "inspect", "render"} {
+		args := []string{command}
+		if command == "render" {
+			args = append(args, "--out", out)
+		}
+		// 缺少 --template 为参数错误
+		if code, _, stderr := runCommand("{}", args...); code != 2 || !strings.Contains(stderr, "--template is required") {
+			t.Errorf("%s 缺少 --template 期望退出码 2，实际 %d %s", command, code, stderr)
+		}
+		// 模板不存在
+		code, _, stderr := runCommand("{}", append(args, "--template", filepath.Join(dir, "missing.xlsx"))...)
+		if code != 1 || !strings.Contains(stderr, "missing.xlsx") {
+			t.Errorf("%s 模板不存在期望退出码 1，实际 %d %s", command, code, stderr)
+		}
+		// 未知参数
+		if code, _, _ := runCommand("{}", append(args, "--unknown")...); code != 2 {
+			t.Errorf("%s 未知参数期望退出码 2，实际 %d", command, code)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	// 示例模板 Sheet1 中的分类汇总行没有分类列
+	code, stdout, stderr := runCommand("", "lint", "--template", templatePath)
+	if code != 1 || stdout != "Sheet1!A12: [subtotal-without-group] subtotal row has no \"分类\" column\n" {
+		t.Errorf("示例模板期望报告分类汇总行的问题，实际 %d %s %s", code, stdout, stderr)
+	}
+
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]any{"表头", "单号"})
+	f.SetSheetRow("Sheet1", "A2", &[]any{"未知", ""})
+	path := filepath.Join(t.TempDir(), "bad.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	code, stdout, _ = runCommand("", "lint", "--template", path)
+	if code != 1 || !strings.Contains(stdout, "Sheet1!A2: [unknown-keyword]") {
+		t.Errorf("有问题时期望退出码 1 并输出问题，实际 %d %s", code, stdout)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	code, stdout, stderr := runCommand("", "schema", "--template", templatePath)
+	if code != 0 {
+		t.Fatalf("期望退出码 0，实际 %d %s", code, stderr)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Type  string
+			Items struct {
+				Properties map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(stdout), &schema); err != nil {
+		t.Fatalf("输出不是 JSON: %v %s", err, stdout)
+	}
+	table := schema.Properties["table"]
+	if table.Type != "array" || table.Items.Properties["订单号"] == nil {
+		t.Errorf("列表字段不正确: %+v", table)
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	content, _ := json.Marshal(map[string]any{
+		"table": []map[string]any{newOrder("a001", "张三"), newOrder("a002", "李四")},
+		"总金额":   100,
+		"对账日期":  "2025年04月28日",
+		"生成日期":  "2025-04-28",
+	})
+	dataPath := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(dataPath, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cellValue := func(path string, sheet string, cell string) string {
+		f, err := excelize.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		value, _ := f.GetCellValue(sheet, cell)
+		return value
+	}
+
+	out := filepath.Join(dir, "out.xlsx")
+	code, _, stderr := runCommand("", "render", "--template", templatePath, "--data", dataPath, "--out", out)
+	if code != 0 {
+		t.Fatalf("期望退出码 0，实际 %d %s", code, stderr)
+	}
+	if value := cellValue(out, "Sheet1", "A6"); value != "A001" {
+		t.Errorf("Sheet1!A6 期望 A001，实际 %q", value)
+	}
+
+	// 从标准输入读取 NDJSON，流式写出
+	ndjson := ""
+	for _, order := range []map[string]any{newOrder("b001", "张三"), newOrder("b002", "王五")} {
+		line, _ := json.Marshal(order)
+		ndjson += string(line) + "\n"
+	}
+	out = filepath.Join(dir, "ndjson.xlsx")
+	code, _, stderr = runCommand(ndjson, "render", "--template", templatePath, "--format", "ndjson", "--streaming", "--out", out)
+	if code != 0 {
+		t.Fatalf("NDJSON 期望退出码 0，实际 %d %s", code, stderr)
+	}
+	if value := cellValue(out, "Sheet1", "A7"); value != "B002" {
+		t.Errorf("Sheet1!A7 期望 B002，实际 %q", value)
+	}
+
+	// 参数错误
+	for _, args := range [][]string{
+		{"--data", dataPath},
+		{"--data", dataPath, "--out", out, "--missing-fields", "unknown"},
+		{"--data", dataPath, "--out", out, "--time-zone", "Unknown/Zone"},
+	} {
+		if code, _, _ := runCommand("", append([]string{"render", "--template", templatePath}, args...)...); code != 2 {
+			t.Errorf("%v 期望退出码 2，实际 %d", args, code)
+		}
+	}
+	// 数据错误
+	code, _, stderr = runCommand("not json", "render", "--template", templatePath, "--out", out)
+	if code != 1 || !strings.Contains(stderr, "failed to decode json") {
+		t.Errorf("数据不是 JSON 期望退出码 1，实际 %d %s", code, stderr)
+	}
+
+	// 收集模式下有单元格错误时仍保存结果，退出码为 1
+	out = filepath.Join(dir, "errors.xlsx")
+	code, _, stderr = runCommand(`{"table":[{"订单号":"c001"}]}`, "render", "--template", templatePath, "--out", out, "--missing-fields", "error", "--collect-errors")
+	if code != 1 || !strings.Contains(stderr, "cells failed to render") {
+		t.Errorf("收集模式期望退出码 1，实际 %d %s", code, stderr)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Errorf("收集模式应保存渲染结果: %v", err)
+	}
+}
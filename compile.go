@@ -0,0 +1,148 @@
+package excel_template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// CompiledTemplate 预先解析好的模板，配置行、列信息和样式只解析一次。
+// 每次渲染都在模板的独立副本上进行，可以被多个 goroutine 并发调用
+type CompiledTemplate struct {
+	TemplatePath string
+	FuncMap      template.FuncMap
+	ListField    string
+	Streaming    bool
+	OnProgress   ProgressFunc
+	// CollectErrors 见 ExcelTemplate.CollectErrors
+	CollectErrors bool
+	// MissingFields 见 ExcelTemplate.MissingFields
+	MissingFields MissingFieldPolicy
+	// TimeZone 见 ExcelTemplate.TimeZone
+	TimeZone *time.Location
+	// AutoFit 见 ExcelTemplate.AutoFit
+	AutoFit *AutoFitOptions
+	// ConditionalColors 见 ExcelTemplate.ConditionalColors
+	ConditionalColors bool
+	// NewFormulaEngine 为每次渲染创建公式引擎，SimpleFormulaEngine 不能并发使用，
+	// 需要共享时可以返回同一个 FormulaEnginePool
+	NewFormulaEngine CreateEngine
+
+	SheetPropsOptions *excelize.SheetPropsOptions
+	PageLayoutOptions *excelize.PageLayoutOptions
+
+	// 已删除配置行列的模板内容
+	content    []byte
+	sheetCache map[string]*SheetCache
+}
+
+// Compile 解析模板并返回可重复渲染的 CompiledTemplate，当前模板不会被修改
+func (et *ExcelTemplate) Compile() (*CompiledTemplate, error) {
+	newFormulaEngine := et.NewFormulaEngine
+	if newFormulaEngine == nil {
+		// 自定义的公式引擎不能保证可以并发使用，需要通过 NewFormulaEngine 指定创建方式
+		if _, ok := et.FormulaEngine.(SimpleFormulaEngine); !ok && et.FormulaEngine != nil {
+			return nil, fmt.Errorf("Compile: custom formula engine requires NewFormulaEngine [path=%s, engine=%T]", et.TemplatePath, et.FormulaEngine)
+		}
+		newFormulaEngine = NewSimpleFormulaEngine
+	}
+	buf, err := et.File.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("Compile: failed to copy template [path=%s]: %w", et.TemplatePath, err)
+	}
+	f, err := excelize.OpenReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Compile: failed to open template copy [path=%s]: %w", et.TemplatePath, err)
+	}
+	defer f.Close()
+
+	prepared := newExcelTemplate(et.TemplatePath, f)
+	prepared.Language = et.Language
+	for _, sheet := range f.GetSheetList() {
+		err = prepared.prepareSheet(sheet)
+		if err != nil {
+			return nil, fmt.Errorf("Compile: failed to prepare sheet [path=%s, sheet=%s]: %w", et.TemplatePath, sheet, err)
+		}
+	}
+	buf, err = f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("Compile: failed to write prepared template [path=%s]: %w", et.TemplatePath, err)
+	}
+
+	return &CompiledTemplate{
+		TemplatePath:      et.TemplatePath,
+		FuncMap:           et.FuncMap,
+		ListField:         et.ListField,
+		Streaming:         et.Streaming,
+		OnProgress:        et.OnProgress,
+		CollectErrors:     et.CollectErrors,
+		MissingFields:     et.MissingFields,
+		TimeZone:          et.TimeZone,
+		AutoFit:           et.AutoFit,
+		ConditionalColors: et.ConditionalColors,
+		NewFormulaEngine:  newFormulaEngine,
+		SheetPropsOptions: et.SheetPropsOptions,
+		PageLayoutOptions: et.PageLayoutOptions,
+		content:           buf.Bytes(),
+		sheetCache:        prepared.SheetCache,
+	}, nil
+}
+
+// CompileFile 打开并解析模板文件
+func CompileFile(templatePath string) (*CompiledTemplate, error) {
+	et, err := OpenFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	defer et.File.Close()
+	return et.Compile()
+}
+
+// Render 在模板副本上渲染数据，返回新的 excelize.File
+func (ct *CompiledTemplate) Render(data any) (*excelize.File, error) {
+	return ct.RenderContext(context.Background(), data)
+}
+
+// RenderContext 在模板副本上渲染数据，支持取消和超时
+func (ct *CompiledTemplate) RenderContext(ctx context.Context, data any) (*excelize.File, error) {
+	et, err := ct.newExcelTemplate()
+	if err != nil {
+		return nil, err
+	}
+	return et.RenderContext(ctx, data)
+}
+
+// newExcelTemplate 创建使用模板副本和预解析结果的渲染器
+func (ct *CompiledTemplate) newExcelTemplate() (*ExcelTemplate, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(ct.content))
+	if err != nil {
+		return nil, fmt.Errorf("CompiledTemplate: failed to open template copy [path=%s]: %w", ct.TemplatePath, err)
+	}
+	// SheetCache 中的列信息只读共享，FillData 每次渲染单独设置
+	sheetCache := make(map[string]*SheetCache, len(ct.sheetCache))
+	for sheet, cache := range ct.sheetCache {
+		sheetCacheCopy := *cache
+		sheetCache[sheet] = &sheetCacheCopy
+	}
+	et := newExcelTemplate(ct.TemplatePath, f)
+	et.SheetCache = sheetCache
+	et.FuncMap = ct.FuncMap
+	et.ListField = ct.ListField
+	et.Streaming = ct.Streaming
+	et.OnProgress = ct.OnProgress
+	et.CollectErrors = ct.CollectErrors
+	et.MissingFields = ct.MissingFields
+	et.TimeZone = ct.TimeZone
+	et.AutoFit = ct.AutoFit
+	et.ConditionalColors = ct.ConditionalColors
+	et.SheetPropsOptions = ct.SheetPropsOptions
+	et.PageLayoutOptions = ct.PageLayoutOptions
+	if ct.NewFormulaEngine != nil {
+		et.FormulaEngine = ct.NewFormulaEngine()
+	}
+	return et, nil
+}
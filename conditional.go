@@ -0,0 +1,251 @@
+package excel_template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// colorLiteralRegexp 匹配颜色表达式中作为结果的颜色常量，如 "ffff00"、"#FF0000"
+var colorLiteralRegexp = regexp.MustCompile(`^"(#?[0-9A-Fa-f]{6})"$`)
+
+// colorRule 颜色表达式结果为 Color 时生效的条件格式公式
+type colorRule struct {
+	Color   string
+	Formula string
+}
+
+// conditionalColor 已写入的条件格式，非流式模式下上方的区域插入行后按最终位置重新生成公式
+type conditionalColor struct {
+	column *Column
+	// 数据字段对应的列名
+	fieldCols map[string]string
+	// 生成公式时条件格式第一个单元格的行号
+	anchorRow int
+}
+
+// setConditionalColors 在 ConditionalColors 模式下将列表区域的背景色、字体色表达式写为数据行范围上的条件格式，
+// 转换成功的列不再逐个单元格计算颜色。表达式引用的字段都是本区域中的数据字段列、且结果为表达式中的颜色常量时才能转换，
+// 横向列表复制出的列和不能转换的列仍按原方式计算。分类汇总行不使用颜色表达式，不在条件格式的范围内
+func (et *ExcelTemplate) setConditionalColors(sheet string, block *TableBlock, fillRowNum int, list []map[string]any) error {
+	if !et.ConditionalColors {
+		return nil
+	}
+	rowRanges := dataRowRanges(fillRowNum, list)
+	if len(rowRanges) == 0 {
+		return nil
+	}
+	fieldCols := make(map[string]string)
+	for _, column := range block.ColumnList {
+		if column.DataField != "" && !column.IsTemplate && column.ItemData == nil {
+			fieldCols[column.DataField] = column.RenderColName
+		}
+	}
+	for _, column := range block.ColumnList {
+		if column.ItemData != nil || (column.BackgroundColorExpr == "" && column.FontColorExpr == "") {
+			continue
+		}
+		// 公式中的相对引用以第一个范围的左上角单元格为基准
+		formats, ok, err := et.conditionalFormats(sheet, column, fieldCols, rowRanges[0][0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		// 合并列的条件格式覆盖整个合并区域
+		startCol, endCol := column.RenderColNum, column.RenderColNum
+		if column.IsMergeCell {
+			startCol, endCol = column.MergeRange.StartCol-1, column.MergeRange.EndCol-1
+		}
+		rangeRef := strings.Join(lo.Map(rowRanges, func(rowRange [2]int, _ int) string {
+			topLeftCell, _ := excelize.CoordinatesToCellName(startCol, rowRange[0])
+			bottomRightCell, _ := excelize.CoordinatesToCellName(endCol, rowRange[1])
+			return topLeftCell + ":" + bottomRightCell
+		}), " ")
+		err = et.File.SetConditionalFormat(sheet, rangeRef, formats)
+		if err != nil {
+			return fmt.Errorf("setConditionalColors: failed to set conditional format [sheet=%s, range=%s]: %w", sheet, rangeRef, err)
+		}
+		if et.conditionalColumns == nil {
+			et.conditionalColumns = make(map[*Column]bool)
+		}
+		et.conditionalColumns[column] = true
+		if et.conditionalColors == nil {
+			et.conditionalColors = make(map[string]map[string]conditionalColor)
+		}
+		if et.conditionalColors[sheet] == nil {
+			et.conditionalColors[sheet] = make(map[string]conditionalColor)
+		}
+		et.conditionalColors[sheet][criteriaKey(formats)] = conditionalColor{column: column, fieldCols: fieldCols, anchorRow: rowRanges[0][0]}
+	}
+	return nil
+}
+
+// conditionalFormats 生成列的颜色表达式对应的条件格式，字段替换为 anchorRow 行中对应列的单元格。
+// 表达式不能转换时返回 false
+func (et *ExcelTemplate) conditionalFormats(sheet string, column *Column, fieldCols map[string]string, anchorRow int) ([]excelize.ConditionalFormatOptions, bool, error) {
+	fieldCells := make(map[string]string, len(fieldCols))
+	for field, colName := range fieldCols {
+		fieldCells[field] = fmt.Sprintf("$%s%d", colName, anchorRow)
+	}
+	bgRules, bgOk := conditionalColorRules(column.BackgroundColorExpr, fieldCells)
+	fontRules, fontOk := conditionalColorRules(column.FontColorExpr, fieldCells)
+	if !bgOk || !fontOk {
+		return nil, false, nil
+	}
+	formats := make([]excelize.ConditionalFormatOptions, 0, len(bgRules)+len(fontRules))
+	for _, rule := range bgRules {
+		style := &excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{rule.Color}}}
+		format, err := et.conditionalStyle("fill-"+rule.Color, style)
+		if err != nil {
+			return nil, false, fmt.Errorf("conditionalFormats: failed to create background style [sheet=%s, color=%s]: %w", sheet, rule.Color, err)
+		}
+		formats = append(formats, excelize.ConditionalFormatOptions{Type: "formula", Criteria: rule.Formula, Format: &format})
+	}
+	for _, rule := range fontRules {
+		style := &excelize.Style{Font: &excelize.Font{Color: rule.Color}}
+		format, err := et.conditionalStyle("font-"+rule.Color, style)
+		if err != nil {
+			return nil, false, fmt.Errorf("conditionalFormats: failed to create font style [sheet=%s, color=%s]: %w", sheet, rule.Color, err)
+		}
+		formats = append(formats, excelize.ConditionalFormatOptions{Type: "formula", Criteria: rule.Formula, Format: &format})
+	}
+	return formats, true, nil
+}
+
+// fixConditionalColors 在工作表的所有区域渲染完成后按条件格式的最终位置重新生成公式。
+// 非流式模式下区域从下往上渲染，上方区域插入行时 excelize 只移动条件格式的范围，不修改公式中的行号
+func (et *ExcelTemplate) fixConditionalColors(sheet string) error {
+	colors := et.conditionalColors[sheet]
+	if len(colors) == 0 {
+		return nil
+	}
+	delete(et.conditionalColors, sheet)
+	formats, err := et.File.GetConditionalFormats(sheet)
+	if err != nil {
+		return fmt.Errorf("fixConditionalColors: failed to get conditional formats [sheet=%s]: %w", sheet, err)
+	}
+	for rangeRef, options := range formats {
+		color, ok := colors[criteriaKey(options)]
+		if !ok {
+			continue
+		}
+		topLeftCell, _, _ := strings.Cut(strings.Fields(rangeRef)[0], ":")
+		_, anchorRow, err := excelize.CellNameToCoordinates(topLeftCell)
+		if err != nil || anchorRow == color.anchorRow {
+			continue
+		}
+		fixed, _, err := et.conditionalFormats(sheet, color.column, color.fieldCols, anchorRow)
+		if err != nil {
+			return err
+		}
+		err = et.File.UnsetConditionalFormat(sheet, rangeRef)
+		if err == nil {
+			err = et.File.SetConditionalFormat(sheet, rangeRef, fixed)
+		}
+		if err != nil {
+			return fmt.Errorf("fixConditionalColors: failed to set conditional format [sheet=%s, range=%s]: %w", sheet, rangeRef, err)
+		}
+	}
+	return nil
+}
+
+// criteriaKey 返回条件格式所有规则公式组成的键，公式中包含生成时的行号，同一工作表中不会重复
+func criteriaKey(formats []excelize.ConditionalFormatOptions) string {
+	return strings.Join(lo.Map(formats, func(format excelize.ConditionalFormatOptions, _ int) string {
+		return format.Criteria
+	}), "\n")
+}
+
+// dataRowRanges 返回列表中连续的非分类汇总行的起止行号
+func dataRowRanges(fillRowNum int, list []map[string]any) [][2]int {
+	ranges := make([][2]int, 0, 1)
+	for i, rowData := range list {
+		if rowData["_row_type"] == "subtotal" {
+			continue
+		}
+		rowNum := fillRowNum + i
+		if n := len(ranges); n > 0 && ranges[n-1][1] == rowNum-1 {
+			ranges[n-1][1] = rowNum
+		} else {
+			ranges = append(ranges, [2]int{rowNum, rowNum})
+		}
+	}
+	return ranges
+}
+
+// conditionalStyle 返回条件格式使用的样式Id，相同颜色的样式只创建一次
+func (et *ExcelTemplate) conditionalStyle(key string, style *excelize.Style) (int, error) {
+	if styleId, ok := et.conditionalStyles[key]; ok {
+		return styleId, nil
+	}
+	styleId, err := et.File.NewConditionalStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	if et.conditionalStyles == nil {
+		et.conditionalStyles = make(map[string]int)
+	}
+	et.conditionalStyles[key] = styleId
+	return styleId, nil
+}
+
+// conditionalColorRules 为表达式中的每个颜色常量生成一条条件格式公式：表达式的结果等于该颜色时生效。
+// 空表达式返回 true 且没有规则；不以 = 开头、引用了 fieldCells 以外的字段或不包含颜色常量时返回 false
+func conditionalColorRules(expr string, fieldCells map[string]string) ([]colorRule, bool) {
+	if expr == "" {
+		return nil, true
+	}
+	if !strings.HasPrefix(expr, "=") {
+		return nil, false
+	}
+	formula, ok := replaceFormulaFields(expr[1:], fieldCells)
+	if !ok {
+		return nil, false
+	}
+	colors := lo.Uniq(lo.FilterMap(formulaStringRegexp.FindAllString(expr, -1), func(literal string, _ int) (string, bool) {
+		match := colorLiteralRegexp.FindStringSubmatch(literal)
+		if match == nil {
+			return "", false
+		}
+		return match[1], true
+	}))
+	if len(colors) == 0 {
+		return nil, false
+	}
+	return lo.Map(colors, func(color string, _ int) colorRule {
+		return colorRule{Color: color, Formula: fmt.Sprintf(`(%s)="%s"`, formula, color)}
+	}), true
+}
+
+// replaceFormulaFields 将公式中字符串常量以外的变量替换为 fieldCells 中的单元格，变量的识别规则与 formulaVariables 一致。
+// 有变量不在 fieldCells 中时返回 false
+func replaceFormulaFields(formula string, fieldCells map[string]string) (string, bool) {
+	ok := true
+	replace := func(part string) string {
+		return formulaVariableRegexp.ReplaceAllStringFunc(part, func(match string) string {
+			if strings.HasSuffix(match, "(") || lo.Contains([]string{"TRUE", "FALSE"}, strings.ToUpper(match)) || (match[0] >= '0' && match[0] <= '9') {
+				return match
+			}
+			cell, found := fieldCells[match]
+			if !found {
+				ok = false
+				return match
+			}
+			return cell
+		})
+	}
+	var builder strings.Builder
+	last := 0
+	for _, loc := range formulaStringRegexp.FindAllStringIndex(formula, -1) {
+		builder.WriteString(replace(formula[last:loc[0]]))
+		builder.WriteString(formula[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	builder.WriteString(replace(formula[last:]))
+	return builder.String(), ok
+}
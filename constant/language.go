@@ -1,44 +1,220 @@
 package constant
 
+import (
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// 配置关键字名称，模板中使用的文字由 Keywords 按语言映射
+const (
+	KeyHeader          = "Header"
+	KeyDataField       = "DataField"
+	KeyData            = "Data"
+	KeyBackgroundColor = "BackgroundColor"
+	KeyFontColor       = "FontColor"
+	KeySubtotal        = "Subtotal"
+	KeyList            = "List"
+	KeySection         = "Section"
+	KeySectionEnd      = "SectionEnd"
+	KeyCloneSheet      = "CloneSheet"
+	KeyHorizontalList  = "HorizontalList"
+	KeyDynamicColumns  = "DynamicColumns"
+	KeyDefault         = "Default"
+	KeyDateLayout      = "DateLayout"
+	KeyNumberFormat    = "NumberFormat"
+	KeyColumnWidth     = "ColumnWidth"
+)
+
+// 分类汇总行中使用的关键字名称，KeyGroup 标记分组字段，其余为汇总函数。
+// 汇总函数名称加 KeySuffix 为分组汇总行的后缀，加 KeyTotal 为总计行的文字
+const (
+	KeyGroup   = "Group"
+	KeySum     = "Sum"
+	KeyCount   = "Count"
+	KeyAverage = "Average"
+	KeyMax     = "Max"
+	KeyMin     = "Min"
+
+	KeySuffix = "Suffix"
+	KeyTotal  = "Total"
+)
+
+// KeyConfigColumn 配置列的标题文字，只用于标记配置列，不是配置关键字，语言包中可以省略
+const KeyConfigColumn = "ConfigColumn"
+
+// 模板中使用的中文关键字文字
+//
+// Deprecated: 关键字文字按模板语言确定，使用 GetKeywords 按关键字名称查找，例如 GetKeywords(Chinese) 返回的字典中 KeyHeader 对应的文字
 var (
-	Header          = "Header"
-	DataField       = "DataField"
-	Data            = "Data"
-	BackgroundColor = "BackgroundColor"
-	FontColor       = "FontColor"
-	Subtotal        = "Subtotal"
+	Header          = "表头"
+	DataField       = "数据字段"
+	Data            = "数据"
+	BackgroundColor = "背景色"
+	FontColor       = "字体色"
+	Subtotal        = "分类汇总"
+	List            = "列表"
+	Section         = "分组"
+	SectionEnd      = "分组结束"
+	CloneSheet      = "复制工作表"
+	HorizontalList  = "横向列表"
+	DynamicColumns  = "动态列"
 )
 
-var languageData = map[string]map[string]string{
-	"en": {
-		"Header":          "Header",
-		"DataField":       "DataField",
-		"Data":            "Data",
-		"BackgroundColor": "BackgroundColor",
-		"FontColor":       "FontColor",
-		"Subtotal":        "Subtotal",
-	},
-	"zh": {
-		"Header":          "表头",
-		"DataField":       "数据字段",
-		"Data":            "数据",
-		"BackgroundColor": "背景色",
-		"FontColor":       "字体色",
-		"Subtotal":        "分类汇总",
-	},
+// 内置语言
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// DefaultLanguage 无法识别模板语言时使用的语言
+const DefaultLanguage = Chinese
+
+// Names 所有配置关键字名称
+var Names = []string{KeyHeader, KeyData, KeyDataField, KeyBackgroundColor, KeyFontColor, KeySubtotal, KeyList, KeySection, KeySectionEnd, KeyCloneSheet, KeyHorizontalList, KeyDynamicColumns, KeyDefault, KeyDateLayout, KeyNumberFormat, KeyColumnWidth}
+
+// SubtotalFuncs 分类汇总函数的关键字名称
+var SubtotalFuncs = []string{KeySum, KeyCount, KeyAverage, KeyMax, KeyMin}
+
+// Keywords 配置关键字字典，键为关键字名称，值为模板中使用的文字
+type Keywords map[string]string
+
+// Lookup 在 names 中按顺序查找模板中的文字对应的关键字名称
+func (k Keywords) Lookup(text string, names []string) (string, bool) {
+	for _, name := range names {
+		if k[name] == text {
+			return name, true
+		}
+	}
+	return "", false
 }
 
-func setLanguage(language string) {
-	if m, ok := languageData[language]; ok {
-		Header = m["Header"]
-		DataField = m["DataField"]
-		Data = m["Data"]
-		BackgroundColor = m["BackgroundColor"]
-		FontColor = m["FontColor"]
-		Subtotal = m["Subtotal"]
+// requiredNames 语言包必须包含的关键字名称
+func requiredNames() []string {
+	names := append([]string{KeyGroup}, Names...)
+	for _, name := range SubtotalFuncs {
+		names = append(names, name, name+KeySuffix, name+KeyTotal)
 	}
+	return names
 }
 
-func init() {
-	setLanguage("zh")
+var (
+	languageMutex sync.RWMutex
+	// languages 按注册顺序排列的语言，自动识别时按此顺序匹配
+	languages    = []string{English, Chinese}
+	languageData = map[string]Keywords{
+		English: {
+			KeyHeader:          "Header",
+			KeyDataField:       "DataField",
+			KeyData:            "Data",
+			KeyBackgroundColor: "BackgroundColor",
+			KeyFontColor:       "FontColor",
+			KeySubtotal:        "Subtotal",
+			KeyList:            "List",
+			KeySection:         "Section",
+			KeySectionEnd:      "SectionEnd",
+			KeyCloneSheet:      "CloneSheet",
+			KeyHorizontalList:  "HorizontalList",
+			KeyDynamicColumns:  "DynamicColumns",
+			KeyDefault:         "Default",
+			KeyDateLayout:      "DateLayout",
+			KeyNumberFormat:    "NumberFormat",
+			KeyColumnWidth:     "ColumnWidth",
+			KeyConfigColumn:    "ConfigColumn",
+
+			KeyGroup:               "Group",
+			KeySum:                 "Sum",
+			KeySum + KeySuffix:     "Total",
+			KeySum + KeyTotal:      "Grand Total",
+			KeyCount:               "Count",
+			KeyCount + KeySuffix:   "Count",
+			KeyCount + KeyTotal:    "Grand Count",
+			KeyAverage:             "Average",
+			KeyAverage + KeySuffix: "Average",
+			KeyAverage + KeyTotal:  "Grand Average",
+			KeyMax:                 "Max",
+			KeyMax + KeySuffix:     "Max",
+			KeyMax + KeyTotal:      "Grand Max",
+			KeyMin:                 "Min",
+			KeyMin + KeySuffix:     "Min",
+			KeyMin + KeyTotal:      "Grand Min",
+		},
+		Chinese: {
+			KeyHeader:          "表头",
+			KeyDataField:       "数据字段",
+			KeyData:            "数据",
+			KeyBackgroundColor: "背景色",
+			KeyFontColor:       "字体色",
+			KeySubtotal:        "分类汇总",
+			KeyList:            "列表",
+			KeySection:         "分组",
+			KeySectionEnd:      "分组结束",
+			KeyCloneSheet:      "复制工作表",
+			KeyHorizontalList:  "横向列表",
+			KeyDynamicColumns:  "动态列",
+			KeyDefault:         "默认值",
+			KeyDateLayout:      "日期解析格式",
+			KeyNumberFormat:    "数字格式",
+			KeyColumnWidth:     "列宽",
+			KeyConfigColumn:    "配置列",
+
+			KeyGroup:               "分类",
+			KeySum:                 "求和",
+			KeySum + KeySuffix:     "汇总",
+			KeySum + KeyTotal:      "总计",
+			KeyCount:               "计数",
+			KeyCount + KeySuffix:   "计数",
+			KeyCount + KeyTotal:    "总计数",
+			KeyAverage:             "平均值",
+			KeyAverage + KeySuffix: "平均值",
+			KeyAverage + KeyTotal:  "总计平均值",
+			KeyMax:                 "最大值",
+			KeyMax + KeySuffix:     "最大值",
+			KeyMax + KeyTotal:      "总计最大值",
+			KeyMin:                 "最小值",
+			KeyMin + KeySuffix:     "最小值",
+			KeyMin + KeyTotal:      "总计最小值",
+		},
+	}
+)
+
+// RegisterLanguage 注册自定义语言包，已存在的语言会被覆盖。keywords 需要包含所有配置关键字和分类汇总关键字
+func RegisterLanguage(language string, keywords Keywords) error {
+	if language == "" {
+		return fmt.Errorf("RegisterLanguage: language is empty")
+	}
+	for _, name := range requiredNames() {
+		if keywords[name] == "" {
+			return fmt.Errorf("RegisterLanguage: missing keyword [language=%s, name=%s]", language, name)
+		}
+	}
+	languageMutex.Lock()
+	defer languageMutex.Unlock()
+	if _, ok := languageData[language]; !ok {
+		languages = append(languages, language)
+	}
+	languageData[language] = maps.Clone(keywords)
+	return nil
+}
+
+// GetKeywords 返回语言对应的关键字字典，返回值只读
+func GetKeywords(language string) (Keywords, bool) {
+	languageMutex.RLock()
+	defer languageMutex.RUnlock()
+	keywords, ok := languageData[language]
+	return keywords, ok
+}
+
+// DetectLanguage 根据配置列中的表头文字识别模板语言，没有表头时返回空字符串
+func DetectLanguage(texts []string) string {
+	languageMutex.RLock()
+	defer languageMutex.RUnlock()
+	for _, text := range texts {
+		for _, language := range languages {
+			if languageData[language][KeyHeader] == text {
+				return language
+			}
+		}
+	}
+	return ""
 }
@@ -0,0 +1,123 @@
+package excel_template
+
+import (
+	"strconv"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// DynamicColumn 由数据定义的列，作为“动态列”配置行所指定列表的元素，也可以直接使用字段相同的 map
+type DynamicColumn struct {
+	// 表头文字
+	Header string `excel:"Header"`
+	// 数据字段，与模板中的数据字段一样支持模板语法
+	Field string `excel:"Field"`
+	// 样式来源列，为模板中同一列表区域内列的表头文字，为空时使用动态列所在的模板列
+	StyleColumn string `excel:"StyleColumn"`
+	// 列宽，为 0 时使用样式来源列的列宽
+	Width float64 `excel:"Width"`
+}
+
+// dynamicHeader 动态列展开后需要写入的表头单元格
+type dynamicHeader struct {
+	CellName string
+	Header   string
+	// 样式来源列的表头单元格，为空时保留复制来的样式
+	StyleCell string
+	Width     float64
+}
+
+// toDynamicColumn 将列表元素转换为 DynamicColumn
+func toDynamicColumn(item map[string]any) DynamicColumn {
+	dc := DynamicColumn{}
+	dc.Header, _ = item["Header"].(string)
+	dc.Field, _ = item["Field"].(string)
+	dc.StyleColumn, _ = item["StyleColumn"].(string)
+	switch width := item["Width"].(type) {
+	case float64:
+		dc.Width = width
+	case float32:
+		dc.Width = float64(width)
+	case int:
+		dc.Width = float64(width)
+	case int64:
+		dc.Width = float64(width)
+	case string:
+		dc.Width, _ = strconv.ParseFloat(width, 64)
+	}
+	return dc
+}
+
+// applyDynamicColumn 使用元素定义的表头、数据字段和样式来源更新展开后的列，并记录需要写入的表头
+func (ce *columnExpansion) applyDynamicColumn(block *TableBlock, column *Column, item map[string]any) {
+	dc := toDynamicColumn(item)
+	if dc.Header != "" {
+		column.Header = dc.Header
+	}
+	if dc.Field != "" {
+		column.DataField = dc.Field
+		column.IsTemplate = ContainsGoTemplateSyntax(dc.Field)
+	}
+	header := dynamicHeader{Header: column.Header, Width: dc.Width}
+	header.CellName, _ = excelize.CoordinatesToCellName(column.RenderColNum, block.StartRowNum-1)
+	if dc.StyleColumn != "" {
+		source, ok := lo.Find(block.ColumnList, func(item *Column) bool {
+			return item.Header == dc.StyleColumn
+		})
+		if ok {
+			// 数据行使用样式来源列的样式，公式仍使用动态列所在模板列的公式
+			column.CellList = lo.Map(column.CellList, func(cell *ColumnCell, index int) *ColumnCell {
+				sourceCell := source.CellList[index%len(source.CellList)]
+				cellCopy := *cell
+				cellCopy.StyleId = sourceCell.StyleId
+				cellCopy.Style = sourceCell.Style
+				return &cellCopy
+			})
+			sourceCol := source.RenderColNum
+			if sourceCol > ce.EndCol {
+				sourceCol += ce.offset()
+			}
+			header.StyleCell, _ = excelize.CoordinatesToCellName(sourceCol, block.StartRowNum-1)
+		}
+	}
+	ce.dynamicHeaders = append(ce.dynamicHeaders, header)
+}
+
+// setDynamicHeaders 写入动态列的表头，并设置表头样式和列宽
+func (et *ExcelTemplate) setDynamicHeaders(sheet string, headers []dynamicHeader) error {
+	for _, header := range headers {
+		err := et.File.SetCellValue(sheet, header.CellName, header.Header)
+		if err != nil {
+			return err
+		}
+		col, _, err := excelize.CellNameToCoordinates(header.CellName)
+		if err != nil {
+			return err
+		}
+		colName, _ := excelize.ColumnNumberToName(col)
+		width := header.Width
+		if header.StyleCell != "" {
+			styleId, err := et.File.GetCellStyle(sheet, header.StyleCell)
+			if err != nil {
+				return err
+			}
+			et.File.SetCellStyle(sheet, header.CellName, header.CellName, styleId)
+			if width == 0 {
+				sourceCol, _, _ := excelize.CellNameToCoordinates(header.StyleCell)
+				sourceColName, _ := excelize.ColumnNumberToName(sourceCol)
+				width, err = et.File.GetColWidth(sheet, sourceColName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if width > 0 {
+			err = et.File.SetColWidth(sheet, colName, colName, width)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
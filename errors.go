@@ -0,0 +1,93 @@
+package excel_template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 渲染错误发生的阶段
+const (
+	// 数据字段缺失
+	PhaseData = "data"
+	// 模板语法渲染失败
+	PhaseTemplate = "template"
+	// 背景色、字体色表达式计算失败
+	PhaseStyle = "style"
+	// 数据行公式的行号替换失败
+	PhaseFormula = "formula"
+	// 图片数据插入失败
+	PhaseImage = "image"
+)
+
+// RenderError 单元格渲染失败的位置和原因，可以通过 errors.As 从 Render 返回的错误中取出
+type RenderError struct {
+	Sheet string
+	// 渲染结果中的单元格位置
+	Cell string
+	// 数据行在列表中的下标，不是数据行时为 -1
+	Row int
+	// 数据字段、模板或表达式
+	Field string
+	Phase string
+	Err   error
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("%s error [sheet=%s, cell=%s, row=%d, field=%s]: %v", e.Phase, e.Sheet, e.Cell, e.Row, e.Field, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// RenderErrors CollectErrors 模式下收集到的所有单元格渲染错误
+type RenderErrors []*RenderError
+
+func (e RenderErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d render errors: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Unwrap 支持 errors.As 和 errors.Is 匹配其中任意一个错误
+func (e RenderErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// locateRenderError 为数据行中尚未设置位置的 RenderError 补充工作表、单元格和数据行下标
+func locateRenderError(err error, sheet string, cellName string, row int) error {
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) && renderErr.Sheet == "" {
+		renderErr.Sheet = sheet
+		renderErr.Cell = cellName
+		renderErr.Row = row
+	}
+	return err
+}
+
+// handleCellError 处理单元格渲染错误，CollectErrors 模式下记录 RenderError 并返回 nil 以继续渲染，
+// 其他错误（如读写工作表失败）仍然中断渲染
+func (et *ExcelTemplate) handleCellError(err error) error {
+	var renderErr *RenderError
+	if et.CollectErrors && errors.As(err, &renderErr) {
+		et.renderErrors = append(et.renderErrors, renderErr)
+		return nil
+	}
+	return err
+}
+
+// imageError 图片数据插入失败时在单元格中写入错误信息并继续渲染，收集模式下同时记录错误
+func (et *ExcelTemplate) imageError(sheet, cellName string, err error) error {
+	setErr := et.File.SetCellValue(sheet, cellName, err.Error())
+	if setErr != nil || !et.CollectErrors {
+		return setErr
+	}
+	return &RenderError{Phase: PhaseImage, Err: err}
+}
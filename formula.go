@@ -3,16 +3,28 @@ package excel_template
 import (
 	"fmt"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
 // 替换公式中的行号为目标行号，并偏移列数
 func ReplaceFormulaRow(formula string, targetRow int, moveColNum int) string {
+	result, err := replaceFormulaRow(formula, targetRow, moveColNum)
+	if err != nil {
+		panic(fmt.Sprintf("解析列号出错: %v", err))
+	}
+	return result
+}
+
+// replaceFormulaRow 同 ReplaceFormulaRow，列号解析失败时返回错误
+func replaceFormulaRow(formula string, targetRow int, moveColNum int) (string, error) {
 	re := regexp.MustCompile(`([$]?)([A-Z]+)([$]?)(\d+)`)
+	var replaceErr error
 	result := re.ReplaceAllStringFunc(formula, func(match string) string {
 		parts := re.FindStringSubmatch(match)
-		if len(parts) < 5 {
+		if len(parts) < 5 || replaceErr != nil {
 			return match
 		}
 		dollarCol := parts[1]
@@ -21,13 +33,16 @@ func ReplaceFormulaRow(formula string, targetRow int, moveColNum int) string {
 		// rowNum := parts[4] // 可用于需要验证的场景
 		colNum, err := excelize.ColumnNameToNumber(colLetters)
 		if err != nil {
-			// return match
-			panic(fmt.Sprintf("解析列号出错: %v", err))
+			replaceErr = err
+			return match
 		}
 		newCol, err := excelize.ColumnNumberToName(colNum + moveColNum)
 		return fmt.Sprintf("%s%s%s%d", dollarCol, newCol, dollarRow, targetRow)
 	})
-	return result
+	if replaceErr != nil {
+		return "", replaceErr
+	}
+	return result, nil
 }
 
 func ReplaceCellRange(s string, replacement string) string {
@@ -35,3 +50,93 @@ func ReplaceCellRange(s string, replacement string) string {
 	re := regexp.MustCompile(`[A-Z]+\d+:[A-Z]+\d+`)
 	return re.ReplaceAllString(s, replacement)
 }
+
+// 匹配公式中的单元格引用，第1组为前导字符，最后一组用于排除函数名（如 LOG10(）
+var cellRefRegexp = regexp.MustCompile(`(^|[^A-Za-z0-9_.!\p{Han}])([$]?)([A-Z]{1,3})([$]?)(\d+)\b(\(?)`)
+
+// ShiftFormulaRows 将公式中行号不小于 fromRow 的单元格引用下移 offset 行，
+// 效果等同于在 fromRow 处插入 offset 行，字符串常量中的内容保持不变
+func ShiftFormulaRows(formula string, fromRow int, offset int) string {
+	if offset == 0 {
+		return formula
+	}
+	parts := strings.Split(formula, `"`)
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = cellRefRegexp.ReplaceAllStringFunc(parts[i], func(match string) string {
+			sub := cellRefRegexp.FindStringSubmatch(match)
+			if sub[6] != "" {
+				return match
+			}
+			row, err := strconv.Atoi(sub[5])
+			if err != nil || row < fromRow {
+				return match
+			}
+			return fmt.Sprintf("%s%s%s%s%d", sub[1], sub[2], sub[3], sub[4], row+offset)
+		})
+	}
+	return strings.Join(parts, `"`)
+}
+
+// ShiftFormulaCols 将公式中列号不小于 fromCol 的单元格引用右移 offset 列，
+// 效果等同于在 fromCol 处插入 offset 列，字符串常量中的内容保持不变
+func ShiftFormulaCols(formula string, fromCol int, offset int) string {
+	return shiftFormulaCols(formula, fromCol, 0, offset, false)
+}
+
+// copyFormulaCols 按复制单元格的规则偏移公式，只有列号在 [fromCol, toCol] 之间的相对引用右移 offset 列
+func copyFormulaCols(formula string, fromCol int, toCol int, offset int) string {
+	return shiftFormulaCols(formula, fromCol, toCol, offset, true)
+}
+
+// shiftFormulaCols 偏移公式中列号在 [fromCol, toCol] 之间的单元格引用，toCol 为 0 时不限制上界
+func shiftFormulaCols(formula string, fromCol int, toCol int, offset int, relativeOnly bool) string {
+	if offset == 0 {
+		return formula
+	}
+	parts := strings.Split(formula, `"`)
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = cellRefRegexp.ReplaceAllStringFunc(parts[i], func(match string) string {
+			sub := cellRefRegexp.FindStringSubmatch(match)
+			if sub[6] != "" || (relativeOnly && sub[2] != "") {
+				return match
+			}
+			col, err := excelize.ColumnNameToNumber(sub[3])
+			if err != nil || col < fromCol || (toCol > 0 && col > toCol) {
+				return match
+			}
+			colName, err := excelize.ColumnNumberToName(col + offset)
+			if err != nil {
+				return match
+			}
+			return sub[1] + sub[2] + colName + sub[4] + sub[5]
+		})
+	}
+	return strings.Join(parts, `"`)
+}
+
+// 匹配公式中的单元格区域，第1组为前导字符，第2组为起始单元格，第3-5组为结束单元格的列和行
+var cellRangeRegexp = regexp.MustCompile(`(^|[^A-Za-z0-9_.!\p{Han}])([$]?[A-Z]{1,3}[$]?\d+):([$]?)([A-Z]{1,3})([$]?\d+)\b`)
+
+// expandFormulaColRanges 将结束列在 [fromCol, toCol] 之间的单元格区域向右扩展 offset 列，
+// 用于横向列表展开后让合计等公式覆盖复制出的列
+func expandFormulaColRanges(formula string, fromCol int, toCol int, offset int) string {
+	if offset <= 0 {
+		return formula
+	}
+	parts := strings.Split(formula, `"`)
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = cellRangeRegexp.ReplaceAllStringFunc(parts[i], func(match string) string {
+			sub := cellRangeRegexp.FindStringSubmatch(match)
+			col, err := excelize.ColumnNameToNumber(sub[4])
+			if err != nil || col < fromCol || col > toCol {
+				return match
+			}
+			colName, err := excelize.ColumnNumberToName(col + offset)
+			if err != nil {
+				return match
+			}
+			return sub[1] + sub[2] + ":" + sub[3] + colName + sub[5]
+		})
+	}
+	return strings.Join(parts, `"`)
+}
@@ -0,0 +1,313 @@
+package excel_template
+
+import (
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// HorizontalList 横向列表，来自模板中的“横向列表”配置行，例如每个月份或每个仓库一列。
+// 列表中的每个元素复制一份 StartCol 到 EndCol 之间的列，包括列宽、样式、合并单元格和图片，
+// 这些列中的模板单元格和数据列使用元素数据渲染
+type HorizontalList struct {
+	// 列表字段
+	ListField string
+	// 删除配置列后的起止列号
+	StartCol int
+	EndCol   int
+	// 是否为动态列，动态列的元素为 DynamicColumn，定义列的表头、数据字段、样式来源和列宽
+	Dynamic bool
+}
+
+// expandColumns 按横向列表插入并复制列，更新本次渲染使用的模板单元格和列信息。
+// 预解析的 SheetCache 可能被多次渲染共享，这里只替换副本中的字段
+func (et *ExcelTemplate) expandColumns(sheet string) error {
+	if len(et.SheetCache[sheet].HorizontalLists) == 0 {
+		return nil
+	}
+	cache := *et.SheetCache[sheet]
+	// 从右往左展开，插入的列不会改变左侧横向列表的位置
+	for i := len(cache.HorizontalLists) - 1; i >= 0; i-- {
+		hl := cache.HorizontalLists[i]
+		expansion := &columnExpansion{HorizontalList: hl, items: getItemList(cache.FillData, hl.ListField)}
+		err := et.copyColumns(sheet, expansion)
+		if err != nil {
+			return fmt.Errorf("expandColumns: failed to copy columns [sheet=%s, list=%s]: %w", sheet, hl.ListField, err)
+		}
+		cache.TemplateCells = expansion.templateCells(cache.TemplateCells)
+		cache.Blocks = expansion.blocks(cache.Blocks)
+		cache.Sections = lo.Map(cache.Sections, func(section *Section, _ int) *Section {
+			sectionCopy := *section
+			sectionCopy.TemplateCells = expansion.templateCells(section.TemplateCells)
+			sectionCopy.Blocks = expansion.blocks(section.Blocks)
+			return &sectionCopy
+		})
+		err = et.setDynamicHeaders(sheet, expansion.dynamicHeaders)
+		if err != nil {
+			return fmt.Errorf("expandColumns: failed to set dynamic column headers [sheet=%s, list=%s]: %w", sheet, hl.ListField, err)
+		}
+	}
+	cache.HorizontalLists = nil
+	et.SheetCache[sheet] = &cache
+	return nil
+}
+
+// columnExpansion 一个横向列表的展开结果
+type columnExpansion struct {
+	*HorizontalList
+	items []map[string]any
+	// 动态列展开后需要写入的表头
+	dynamicHeaders []dynamicHeader
+}
+
+// width 每个元素占用的列数
+func (ce *columnExpansion) width() int {
+	return ce.EndCol - ce.StartCol + 1
+}
+
+// offset 横向列表右侧的列移动的列数，列表为空时删除横向列表的列
+func (ce *columnExpansion) offset() int {
+	return (len(ce.items) - 1) * ce.width()
+}
+
+// copyColumns 在横向列表右侧插入列并复制列宽、样式、单元格、合并单元格和图片，列表为空时删除这些列
+func (et *ExcelTemplate) copyColumns(sheet string, ce *columnExpansion) error {
+	if len(ce.items) == 0 {
+		for col := ce.EndCol; col >= ce.StartCol; col-- {
+			colName, _ := excelize.ColumnNumberToName(col)
+			err := et.File.RemoveCol(sheet, colName)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(ce.items) == 1 {
+		return nil
+	}
+	width := ce.width()
+	inColumns := func(col int) bool {
+		return col >= ce.StartCol && col <= ce.EndCol
+	}
+	insertColName, err := excelize.ColumnNumberToName(ce.EndCol + 1)
+	if err != nil {
+		return err
+	}
+	err = et.File.InsertCols(sheet, insertColName, ce.offset())
+	if err != nil {
+		return err
+	}
+
+	maxRow, _, err := et.getSheetBounds(sheet)
+	if err != nil {
+		return err
+	}
+	mergeCells, err := et.File.GetMergeCells(sheet)
+	if err != nil {
+		return err
+	}
+	mergeRanges := parseMergeCells(mergeCells)
+	pictureCells, err := et.File.GetPictureCells(sheet)
+	if err != nil {
+		return err
+	}
+
+	for col := ce.StartCol; col <= ce.EndCol; col++ {
+		colName, _ := excelize.ColumnNumberToName(col)
+		colWidth, err := et.File.GetColWidth(sheet, colName)
+		if err != nil {
+			return err
+		}
+		colStyle, err := et.File.GetColStyle(sheet, colName)
+		if err != nil {
+			return err
+		}
+		for i := 1; i < len(ce.items); i++ {
+			targetColName, _ := excelize.ColumnNumberToName(col + i*width)
+			et.File.SetColWidth(sheet, targetColName, targetColName, colWidth)
+			et.File.SetColStyle(sheet, targetColName, colStyle)
+		}
+		for rowNum := 1; rowNum <= maxRow; rowNum++ {
+			cellName, _ := excelize.CoordinatesToCellName(col, rowNum)
+			cell, err := et.readTemplateCell(sheet, cellName)
+			if err != nil {
+				return err
+			}
+			if cell == nil {
+				continue
+			}
+			// 合并区域内只复制左上角单元格的内容
+			if lo.ContainsBy(mergeRanges, func(m MergeRange) bool {
+				return col >= m.StartCol && col <= m.EndCol && rowNum >= m.StartRow && rowNum <= m.EndRow && (col != m.StartCol || rowNum != m.StartRow)
+			}) {
+				cell.Value = nil
+				cell.Formula = ""
+			}
+			for i := 1; i < len(ce.items); i++ {
+				targetCell, _ := excelize.CoordinatesToCellName(col+i*width, rowNum)
+				et.File.SetCellStyle(sheet, targetCell, targetCell, cell.StyleID)
+				if cell.Formula != "" {
+					et.File.SetCellFormula(sheet, targetCell, copyFormulaCols(cell.Formula, ce.StartCol, ce.EndCol, i*width))
+				} else if cell.Value != nil {
+					et.File.SetCellValue(sheet, targetCell, cell.Value)
+				}
+			}
+		}
+	}
+
+	// 横向列表之外的公式中，结束列位于横向列表的区域扩展到复制出的列
+	_, maxCol, err := et.getSheetBounds(sheet)
+	if err != nil {
+		return err
+	}
+	for col := 1; col <= maxCol; col++ {
+		if col >= ce.StartCol && col <= ce.EndCol+ce.offset() {
+			continue
+		}
+		for rowNum := 1; rowNum <= maxRow; rowNum++ {
+			cellName, _ := excelize.CoordinatesToCellName(col, rowNum)
+			formula, err := et.File.GetCellFormula(sheet, cellName)
+			if err != nil {
+				return err
+			}
+			if formula == "" {
+				continue
+			}
+			if expanded := expandFormulaColRanges(formula, ce.StartCol, ce.EndCol, ce.offset()); expanded != formula {
+				et.File.SetCellFormula(sheet, cellName, expanded)
+			}
+		}
+	}
+
+	for i := 1; i < len(ce.items); i++ {
+		offset := i * width
+		for _, mergeRange := range mergeRanges {
+			if !inColumns(mergeRange.StartCol) || !inColumns(mergeRange.EndCol) {
+				continue
+			}
+			topLeftCell, _ := excelize.CoordinatesToCellName(mergeRange.StartCol+offset, mergeRange.StartRow)
+			bottomRightCell, _ := excelize.CoordinatesToCellName(mergeRange.EndCol+offset, mergeRange.EndRow)
+			err = et.File.MergeCell(sheet, topLeftCell, bottomRightCell)
+			if err != nil {
+				return err
+			}
+		}
+		for _, cellName := range pictureCells {
+			col, row, err := excelize.CellNameToCoordinates(cellName)
+			if err != nil || !inColumns(col) {
+				continue
+			}
+			pictures, err := et.File.GetPictures(sheet, cellName)
+			if err != nil {
+				return err
+			}
+			targetCell, _ := excelize.CoordinatesToCellName(col+offset, row)
+			for _, picture := range pictures {
+				err = et.File.AddPictureFromBytes(sheet, targetCell, &picture)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// templateCells 计算展开后模板单元格的位置，横向列表中的单元格按元素复制并绑定元素数据
+func (ce *columnExpansion) templateCells(templateCells []TemplateCell) []TemplateCell {
+	result := make([]TemplateCell, 0, len(templateCells))
+	for _, templateCell := range templateCells {
+		col, row, err := excelize.CellNameToCoordinates(templateCell.CellName)
+		if err != nil || col < ce.StartCol {
+			result = append(result, templateCell)
+			continue
+		}
+		if col > ce.EndCol {
+			templateCell.CellName, _ = excelize.CoordinatesToCellName(col+ce.offset(), row)
+			result = append(result, templateCell)
+			continue
+		}
+		for i, item := range ce.items {
+			cellCopy := templateCell
+			cellCopy.CellName, _ = excelize.CoordinatesToCellName(col+i*ce.width(), row)
+			cellCopy.Data = mergeFillData(templateCell.Data, item)
+			result = append(result, cellCopy)
+		}
+	}
+	return result
+}
+
+// blocks 计算展开后列表区域的列信息，横向列表中的列按元素复制并绑定元素数据
+func (ce *columnExpansion) blocks(blocks []*TableBlock) []*TableBlock {
+	return lo.Map(blocks, func(block *TableBlock, _ int) *TableBlock {
+		blockCopy := *block
+		left, columns, right := make([]*Column, 0), make([]*Column, 0), make([]*Column, 0)
+		for _, column := range block.ColumnList {
+			switch {
+			case column.RenderColNum < ce.StartCol:
+				left = append(left, ce.shiftColumn(column, 0, nil))
+			case column.RenderColNum > ce.EndCol:
+				right = append(right, ce.shiftColumn(column, ce.offset(), nil))
+			default:
+				columns = append(columns, column)
+			}
+		}
+		blockCopy.ColumnList = left
+		for i, item := range ce.items {
+			for _, column := range columns {
+				columnCopy := ce.shiftColumn(column, i*ce.width(), item)
+				if ce.Dynamic {
+					// 动态列的元素只用于定义列，不作为行数据
+					columnCopy.ItemData = column.ItemData
+					ce.applyDynamicColumn(block, columnCopy, item)
+				}
+				blockCopy.ColumnList = append(blockCopy.ColumnList, columnCopy)
+			}
+		}
+		blockCopy.ColumnList = append(blockCopy.ColumnList, right...)
+		return &blockCopy
+	})
+}
+
+// shiftColumn 复制列信息并右移 offset 列，item 不为 nil 时为横向列表中的列，公式中对横向列表的相对引用同样右移
+func (ce *columnExpansion) shiftColumn(column *Column, offset int, item map[string]any) *Column {
+	columnCopy := *column
+	columnCopy.ColNum += offset
+	columnCopy.ColName, _ = excelize.ColumnNumberToName(columnCopy.ColNum)
+	columnCopy.RenderColNum += offset
+	columnCopy.RenderColName, _ = excelize.ColumnNumberToName(columnCopy.RenderColNum)
+	if column.MergeRange != nil {
+		mergeRange := *column.MergeRange
+		mergeRange.StartCol += offset
+		mergeRange.EndCol += offset
+		mergeRange.StartCell, _ = excelize.CoordinatesToCellName(mergeRange.StartCol, mergeRange.StartRow)
+		mergeRange.EndCell, _ = excelize.CoordinatesToCellName(mergeRange.EndCol, mergeRange.EndRow)
+		columnCopy.MergeRange = &mergeRange
+	}
+	if item != nil {
+		columnCopy.ItemData = mergeFillData(column.ItemData, item)
+	}
+	// 数据行公式使用包含配置列的模板列号
+	columnCopy.CellList = lo.Map(column.CellList, func(cell *ColumnCell, _ int) *ColumnCell {
+		cellCopy := *cell
+		if cellCopy.Formula != "" {
+			cellCopy.Formula = ShiftFormulaCols(cellCopy.Formula, ce.EndCol+2, ce.offset())
+			if item != nil {
+				cellCopy.Formula = copyFormulaCols(cellCopy.Formula, ce.StartCol+1, ce.EndCol+1, offset)
+			} else {
+				cellCopy.Formula = expandFormulaColRanges(cellCopy.Formula, ce.StartCol+1, ce.EndCol+1, ce.offset())
+			}
+		}
+		return &cellCopy
+	})
+	return &columnCopy
+}
+
+// cellData 返回渲染该列使用的数据和公式结果缓存，横向列表中的列合并元素数据并使用单独的缓存
+func (column *Column) cellData(rowData map[string]any, formulaResultCache map[string]any) (map[string]any, map[string]any) {
+	if column.ItemData == nil {
+		return rowData, formulaResultCache
+	}
+	return mergeFillData(rowData, column.ItemData), make(map[string]any)
+}
@@ -0,0 +1,182 @@
+package excel_template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mzzya/excel_template/constant"
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// 模板检查规则
+const (
+	// 读取工作表失败
+	IssueReadError = "read-error"
+	// 指定的语言不存在
+	IssueUnknownLanguage = "unknown-language"
+	// 配置列中的文字不是配置关键字
+	IssueUnknownKeyword = "unknown-keyword"
+	// 数据行所在的列表区域没有表头行
+	IssueDataWithoutHeader = "data-without-header"
+	// 分类汇总行没有分类列
+	IssueSubtotalWithoutGroup = "subtotal-without-group"
+	// 数据字段中的模板语法错误
+	IssueInvalidTemplate = "invalid-template"
+	// 颜色表达式不是以 = 开头的公式
+	IssueInvalidColorExpr = "invalid-color-expression"
+	// 列宽不是数字或 auto
+	IssueInvalidColumnWidth = "invalid-column-width"
+	// 公式引用了渲染时会删除的配置列
+	IssueConfigColumnReference = "config-column-reference"
+)
+
+// Issue 模板检查发现的问题，Cell 为模板中的单元格位置（包含配置列）
+type Issue struct {
+	Sheet   string `json:"sheet"`
+	Cell    string `json:"cell,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (issue Issue) String() string {
+	if issue.Cell == "" {
+		return fmt.Sprintf("%s: [%s] %s", issue.Sheet, issue.Rule, issue.Message)
+	}
+	return fmt.Sprintf("%s!%s: [%s] %s", issue.Sheet, issue.Cell, issue.Rule, issue.Message)
+}
+
+// Lint 在渲染前检查模板配置，返回发现的问题，模板不会被修改。
+// 模板语法使用 FuncMap 检查，FuncMap 为 nil 时不检查函数是否存在
+func (et *ExcelTemplate) Lint() []Issue {
+	issues := make([]Issue, 0)
+	for _, sheet := range et.File.GetSheetList() {
+		issues = append(issues, et.lintSheet(sheet)...)
+	}
+	return issues
+}
+
+// lintSheet 检查单个工作表，没有配置行的工作表不会删除配置列，不做检查
+func (et *ExcelTemplate) lintSheet(sheet string) []Issue {
+	rows, _, err := et.getSheetData(sheet)
+	if err != nil {
+		return []Issue{{Sheet: sheet, Rule: IssueReadError, Message: err.Error()}}
+	}
+	keywords, err := et.sheetKeywords(rows)
+	if err != nil {
+		return []Issue{{Sheet: sheet, Rule: IssueUnknownLanguage, Message: err.Error()}}
+	}
+	configured := lo.SomeBy(rows, func(row []string) bool {
+		_, ok := keywords.Lookup(lo.FirstOr(row, ""), constant.Names)
+		return ok
+	})
+	if !configured {
+		return nil
+	}
+
+	issues := make([]Issue, 0)
+	addIssue := func(col int, rowNum int, rule string, format string, args ...any) {
+		cellName, _ := excelize.CoordinatesToCellName(col, rowNum)
+		issues = append(issues, Issue{Sheet: sheet, Cell: cellName, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+	// 列表区域从表头行开始，第一个表头之前的行属于第一个列表区域，分组配置行划分新的区域
+	hasHeader := false
+	dataRowNums := make([]int, 0)
+	endBlock := func() {
+		if !hasHeader {
+			for _, rowNum := range dataRowNums {
+				addIssue(1, rowNum, IssueDataWithoutHeader, "data row has no %q row in its table block", keywords[constant.KeyHeader])
+			}
+		}
+		hasHeader = false
+		dataRowNums = dataRowNums[:0]
+	}
+	for rowIndex, row := range rows {
+		rowNum := rowIndex + 1
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+		name, ok := keywords.Lookup(row[0], constant.Names)
+		if !ok {
+			if row[0] != keywords[constant.KeyConfigColumn] {
+				addIssue(1, rowNum, IssueUnknownKeyword, "unknown config keyword %q", row[0])
+			}
+			continue
+		}
+		switch name {
+		case constant.KeyHeader:
+			if hasHeader {
+				endBlock()
+			}
+			hasHeader = true
+			dataRowNums = dataRowNums[:0]
+		case constant.KeySection, constant.KeySectionEnd:
+			endBlock()
+		case constant.KeyData:
+			dataRowNums = append(dataRowNums, rowNum)
+		case constant.KeySubtotal:
+			if !lo.Contains(row[1:], keywords[constant.KeyGroup]) {
+				addIssue(1, rowNum, IssueSubtotalWithoutGroup, "subtotal row has no %q column", keywords[constant.KeyGroup])
+			}
+		case constant.KeyDataField:
+			for colIndex, value := range row[1:] {
+				if !strings.Contains(value, "{{") && !strings.Contains(value, "}}") {
+					continue
+				}
+				if err := CheckTemplateSyntax(value, et.FuncMap); err != nil {
+					addIssue(colIndex+2, rowNum, IssueInvalidTemplate, "invalid template syntax: %v", err)
+				}
+			}
+		case constant.KeyBackgroundColor, constant.KeyFontColor:
+			for colIndex, value := range row[1:] {
+				if value != "" && !strings.HasPrefix(strings.TrimSpace(value), "=") {
+					addIssue(colIndex+2, rowNum, IssueInvalidColorExpr, "color expression must start with \"=\": %q", value)
+				}
+			}
+		case constant.KeyColumnWidth:
+			for colIndex, value := range row[1:] {
+				if _, _, err := parseColumnWidth(value); err != nil {
+					addIssue(colIndex+2, rowNum, IssueInvalidColumnWidth, "%v", err)
+				}
+			}
+		}
+	}
+	endBlock()
+
+	return append(issues, et.lintFormulas(sheet, rows)...)
+}
+
+// 匹配公式中从 A 列开始的整列引用（如 A:A、$A:$C）
+var configColumnRangeRegexp = regexp.MustCompile(`(^|[^A-Za-z0-9_.!\p{Han}])[$]?A:[$]?[A-Z]{1,3}($|[^A-Za-z0-9_(])`)
+
+// lintFormulas 检查公式是否引用了配置列，配置列删除后这些引用会指向错误的单元格
+func (et *ExcelTemplate) lintFormulas(sheet string, rows [][]string) []Issue {
+	issues := make([]Issue, 0)
+	maxCol := lo.Max(lo.Map(rows, func(row []string, _ int) int {
+		return len(row)
+	}))
+	if dimension, err := et.File.GetSheetDimension(sheet); err == nil {
+		if _, endCell, ok := strings.Cut(dimension, ":"); ok {
+			if col, _, err := excelize.CellNameToCoordinates(endCell); err == nil {
+				maxCol = max(maxCol, col)
+			}
+		}
+	}
+	for rowIndex := range rows {
+		for col := 1; col <= maxCol; col++ {
+			cellName, _ := excelize.CoordinatesToCellName(col, rowIndex+1)
+			formula, err := et.File.GetCellFormula(sheet, cellName)
+			if err != nil || formula == "" {
+				continue
+			}
+			referenced := lo.SomeBy(cellRefRegexp.FindAllStringSubmatch(formula, -1), func(match []string) bool {
+				return match[3] == "A" && match[6] == ""
+			}) || configColumnRangeRegexp.MatchString(formula)
+			if referenced {
+				issues = append(issues, Issue{Sheet: sheet, Cell: cellName, Rule: IssueConfigColumnReference, Message: fmt.Sprintf("formula references config column A: =%s", formula)})
+			}
+		}
+	}
+	return issues
+}
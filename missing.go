@@ -0,0 +1,129 @@
+package excel_template
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// MissingFieldPolicy 填充数据中缺少字段时的处理方式，对数据字段、模板语法和颜色表达式中的变量统一生效。
+// 只检查字段是否存在，值为 nil 的字段不算缺少
+type MissingFieldPolicy string
+
+const (
+	// MissingFieldIgnore 默认处理方式：数据字段留空，模板输出 <no value>，颜色表达式中的变量保留原文
+	MissingFieldIgnore MissingFieldPolicy = ""
+	// MissingFieldError 缺少字段时返回包含 ErrMissingField 的 RenderError
+	MissingFieldError MissingFieldPolicy = "error"
+	// MissingFieldBlank 缺少的字段按空值处理：数据字段留空，模板输出空字符串，颜色表达式中为空单元格
+	MissingFieldBlank MissingFieldPolicy = "blank"
+	// MissingFieldDefault 使用列表区域中默认值配置行声明的值，没有声明默认值的字段按空值处理
+	MissingFieldDefault MissingFieldPolicy = "default"
+)
+
+// ErrMissingField 填充数据中缺少字段，MissingFields 为 MissingFieldError 时返回
+var ErrMissingField = errors.New("missing field")
+
+// fillDefaults 返回补齐了默认值的行数据，只在 MissingFieldDefault 模式下生效，没有缺少的字段时返回原数据
+func (et *ExcelTemplate) fillDefaults(rowData map[string]any, defaults map[string]any) map[string]any {
+	if et.MissingFields != MissingFieldDefault {
+		return rowData
+	}
+	var filled map[string]any
+	for field, value := range defaults {
+		if _, ok := rowData[field]; ok {
+			continue
+		}
+		if filled == nil {
+			filled = make(map[string]any, len(rowData)+len(defaults))
+			maps.Copy(filled, rowData)
+		}
+		filled[field] = value
+	}
+	if filled == nil {
+		return rowData
+	}
+	return filled
+}
+
+// fillMissingFields 按 MissingFields 检查 fields 是否都在数据中，缺少时返回错误或用 blank 补齐。
+// 模板中 blank 使用 "" 避免输出 <no value>，颜色表达式中使用 nil 作为空单元格
+func (et *ExcelTemplate) fillMissingFields(data map[string]any, fields []string, blank any) (map[string]any, error) {
+	if et.MissingFields == MissingFieldIgnore {
+		return data, nil
+	}
+	var filled map[string]any
+	for _, field := range fields {
+		if _, ok := data[field]; ok {
+			continue
+		}
+		if et.MissingFields == MissingFieldError {
+			return nil, fmt.Errorf("%w [field=%s]", ErrMissingField, field)
+		}
+		if filled == nil {
+			filled = make(map[string]any, len(data)+len(fields))
+			maps.Copy(filled, data)
+		}
+		filled[field] = blank
+	}
+	if filled == nil {
+		return data, nil
+	}
+	return filled, nil
+}
+
+// templateFieldNames 返回模板中引用的顶层字段名
+func templateFieldNames(tmplStr string) []string {
+	return lo.Uniq(lo.Map(templateFields(tmplStr), func(path []string, _ int) string {
+		return path[0]
+	}))
+}
+
+// blockDefaults 收集列表区域中声明了默认值的字段。模板字段列中的默认值作用于模板引用的字段，
+// 字段所在的数据字段列也声明了默认值时以数据字段列为准
+func blockDefaults(columns []*Column) map[string]any {
+	defaults := make(map[string]any)
+	for _, column := range columns {
+		if column.DefaultValue == nil || column.IsTemplate || column.DataField == "" {
+			continue
+		}
+		defaults[column.DataField] = column.DefaultValue
+	}
+	for _, column := range columns {
+		if column.DefaultValue == nil || !column.IsTemplate {
+			continue
+		}
+		for _, field := range templateFieldNames(column.DataField) {
+			if _, ok := defaults[field]; !ok {
+				defaults[field] = column.DefaultValue
+			}
+		}
+	}
+	return defaults
+}
+
+// readDefaultValue 读取默认值配置行中的单元格，数字单元格按数字返回，空单元格返回 nil
+func (et *ExcelTemplate) readDefaultValue(sheet string, cellName string, value string) (any, error) {
+	if value == "" {
+		return nil, nil
+	}
+	cellType, err := et.File.GetCellType(sheet, cellName)
+	if err != nil {
+		return nil, err
+	}
+	if cellType != excelize.CellTypeUnset && cellType != excelize.CellTypeNumber {
+		return value, nil
+	}
+	raw, err := et.File.GetCellValue(sheet, cellName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	if number, err := strconv.ParseFloat(raw, 64); err == nil {
+		return number, nil
+	}
+	return value, nil
+}
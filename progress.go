@@ -0,0 +1,64 @@
+package excel_template
+
+import (
+	"context"
+	"fmt"
+)
+
+// progressInterval 每写入多少行数据报告一次进度，每个工作表结束时总会报告一次
+const progressInterval = 100
+
+// RenderProgress 工作表的渲染进度，RowsDone 和 TotalRows 累计工作表中所有列表区域（包括分组区域中的每个副本），包含分类汇总行
+type RenderProgress struct {
+	Sheet     string
+	RowsDone  int
+	TotalRows int
+}
+
+// ProgressFunc 渲染进度回调，在渲染所在的 goroutine 中同步调用
+type ProgressFunc func(progress RenderProgress)
+
+// startProgress 开始统计工作表的进度
+func (et *ExcelTemplate) startProgress(sheet string, totalRows int) {
+	et.progress = RenderProgress{Sheet: sheet, TotalRows: totalRows}
+}
+
+// checkRowProgress 在写入每行数据前检查是否已取消，并按间隔报告进度，row 为数据行在列表中的下标
+func (et *ExcelTemplate) checkRowProgress(ctx context.Context, sheet string, row int) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("render canceled [sheet=%s, row=%d]: %w", sheet, row, err)
+	}
+	if et.OnProgress != nil && et.progress.RowsDone > 0 && et.progress.RowsDone%progressInterval == 0 {
+		et.OnProgress(et.progress)
+	}
+	et.progress.RowsDone++
+	return nil
+}
+
+// reportProgress 报告工作表的最终进度，工作表没有数据行时不报告
+func (et *ExcelTemplate) reportProgress() {
+	if et.OnProgress != nil && et.progress.TotalRows > 0 {
+		et.OnProgress(et.progress)
+	}
+}
+
+// sheetRowCount 统计非流式模式下工作表写入的数据行总数，只在设置了 OnProgress 时统计
+func (et *ExcelTemplate) sheetRowCount(sheet string) int {
+	if et.OnProgress == nil {
+		return 0
+	}
+	cache := et.SheetCache[sheet]
+	total := 0
+	for _, block := range cache.Blocks {
+		total += len(et.blockList(sheet, block, cache.FillData))
+	}
+	for _, section := range cache.Sections {
+		for _, item := range getList(cache.FillData, section.ListField) {
+			itemData := mergeFillData(cache.FillData, item)
+			for _, block := range section.Blocks {
+				total += len(et.blockList(sheet, block, itemData))
+			}
+		}
+	}
+	return total
+}
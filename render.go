@@ -2,10 +2,14 @@ package excel_template
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
+	"io"
+	"io/fs"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/mzzya/excel_template/constant"
 	"github.com/tiendc/go-deepcopy"
@@ -59,17 +63,57 @@ type Column struct {
 	IsTemplate          bool
 	BackgroundColorExpr string
 	FontColorExpr       string
+	// 数字格式配置行中的格式代码，如 #,##0.00，以 = 开头时为按行计算格式代码的公式
+	NumberFormat string
+	// 列宽配置行中的固定列宽，为 0 时保持模板中的列宽
+	Width float64
+	// 列宽配置为 auto 时按表头和数据的文字计算列宽
+	AutoWidth bool
+	// 默认值配置行中声明的值，MissingFieldDefault 模式下数据中缺少该字段时使用
+	DefaultValue any
+	// 日期解析格式配置行中的 Go 时间格式，如 2006-01-02 15:04:05，文本按此格式解析为日期
+	DateLayout string
+	// 横向列表复制出的列对应的元素数据，渲染时覆盖行数据中的同名字段
+	ItemData map[string]any
 
 	CellList []*ColumnCell
 }
 
-type SheetCache struct {
+// TemplateCell 模板中使用整体数据渲染的单元格，CellName 为删除配置行列后的位置
+type TemplateCell struct {
+	CellName string
+	Template string
+	// 横向列表复制出的单元格对应的元素数据，渲染时覆盖填充数据中的同名字段
+	Data map[string]any
+}
+
+// TableBlock 工作表中的一个列表区域，从表头配置行开始，到下一个表头配置行之前结束。
+// 同一工作表中可以上下排列多个列表区域，分别绑定列表数据、样式和分类汇总
+type TableBlock struct {
 	Config        map[string][][]string
 	ColumnList    []*Column
 	StartRowNum   int
-	FillData      map[string]any
 	DataRowHeight float64
-	MergeRanges   []MergeRange
+	// 模板中保留的数据行数（1或2），数据行从第2行开始插入
+	TemplateDataRows int
+	// 模板中配置的列表字段，为空时使用 ExcelTemplate.ListField
+	ListField string
+	// 默认值配置行声明的字段默认值，键为数据字段
+	Defaults map[string]any
+}
+
+type SheetCache struct {
+	// 按在工作表中的位置从上到下排列的列表区域，不包含分组区域内的列表区域
+	Blocks   []*TableBlock
+	Sections []*Section
+	// 按列表元素复制工作表的配置，为 nil 时直接渲染当前工作表
+	Clone           *CloneSheet
+	HorizontalLists []*HorizontalList
+	FillData        map[string]any
+	MergeRanges     []MergeRange
+	TemplateCells   []TemplateCell
+	// 工作表使用的关键字字典，分类汇总行的文字按此生成
+	Keywords constant.Keywords
 }
 
 // ExcelTemplate 表示Excel模板渲染器
@@ -78,14 +122,49 @@ type ExcelTemplate struct {
 	File          *excelize.File
 	SheetCache    map[string]*SheetCache
 	FormulaEngine FormulaEngine
-	FuncMap       template.FuncMap
-	ListField     string
+	// NewFormulaEngine 编译后每次渲染创建公式引擎的函数，为空时使用 NewSimpleFormulaEngine。
+	// FormulaEngine 不是 SimpleFormulaEngine 时必须设置，否则 Compile 返回错误
+	NewFormulaEngine CreateEngine
+	FuncMap          template.FuncMap
+	ListField        string
+	// Streaming 为 true 时使用 StreamWriter 写出数据行，适用于数据量很大的列表。
+	// 包含分组区域的工作表需要复制模板行，不支持流式写出，这些工作表仍使用普通模式渲染
+	Streaming bool
+	// Language 配置关键字使用的语言（constant.English、constant.Chinese），
+	// 为空时按每个工作表配置列中的表头文字自动识别，无法识别时使用中文
+	Language string
+	// OnProgress 可选的渲染进度回调
+	OnProgress ProgressFunc
+	// CollectErrors 为 true 时单元格渲染失败不中断渲染，结束后返回渲染结果和包含所有失败的 RenderErrors
+	CollectErrors bool
+	// MissingFields 填充数据中缺少字段时的处理方式，默认保持原有行为
+	MissingFields MissingFieldPolicy
+	// TimeZone 写入单元格的时间先转换到此时区，解析没有时区的文本时也使用此时区；为 nil 时使用时间本身的时区
+	TimeZone *time.Location
 
 	SheetPropsOptions *excelize.SheetPropsOptions
 	PageLayoutOptions *excelize.PageLayoutOptions
-}
 
-var configKeys = []string{constant.Header, constant.Data, constant.DataField, constant.BackgroundColor, constant.FontColor, constant.Subtotal}
+	// AutoFit 不为 nil 时按内容计算列表区域中未配置固定列宽的列宽，并调整有自动换行单元格的数据行行高
+	AutoFit *AutoFitOptions
+	// ConditionalColors 为 true 时将背景色、字体色表达式写为 Excel 条件格式，修改数据后颜色仍会更新，也不再逐个单元格计算颜色
+	ConditionalColors bool
+
+	// CollectErrors 模式下本次渲染收集到的错误
+	renderErrors RenderErrors
+	// 当前工作表的渲染进度
+	progress RenderProgress
+	// 写入时间的单元格使用的样式，键为原样式Id和时间格式
+	timeStyles map[[2]int]int
+	// 数字格式配置生成的样式，键为原样式Id和格式代码
+	numFmtStyles map[string]int
+	// 颜色表达式已写为条件格式的列
+	conditionalColumns map[*Column]bool
+	// 条件格式使用的样式，键为颜色类型和颜色
+	conditionalStyles map[string]int
+	// 已写入的条件格式，键为工作表名称和 criteriaKey
+	conditionalColors map[string]map[string]conditionalColor
+}
 
 // var formulaEngine FormulaEngine
 
@@ -100,17 +179,52 @@ var configKeys = []string{constant.Header, constant.Data, constant.DataField, co
 func OpenFile(templatePath string) (*ExcelTemplate, error) {
 	f, err := excelize.OpenFile(templatePath)
 	if err != nil {
-		fmt.Println(err)
 		return nil, fmt.Errorf("OpenFile: failed to open Excel file [path=%s]: %w", templatePath, err)
 	}
-	et := &ExcelTemplate{
+	return newExcelTemplate(templatePath, f), nil
+}
+
+// OpenReader 从 io.Reader 读取模板并创建Excel模板渲染器
+func OpenReader(r io.Reader) (*ExcelTemplate, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("OpenReader: failed to open Excel reader: %w", err)
+	}
+	return newExcelTemplate("<reader>", f), nil
+}
+
+// OpenBytes 从内存中的模板内容创建Excel模板渲染器
+func OpenBytes(content []byte) (*ExcelTemplate, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("OpenBytes: failed to open Excel bytes [size=%d]: %w", len(content), err)
+	}
+	return newExcelTemplate(fmt.Sprintf("<bytes:%d>", len(content)), f), nil
+}
+
+// OpenFS 从文件系统（如 embed.FS）中读取模板并创建Excel模板渲染器
+func OpenFS(fsys fs.FS, name string) (*ExcelTemplate, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("OpenFS: failed to open template [name=%s]: %w", name, err)
+	}
+	defer file.Close()
+	f, err := excelize.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("OpenFS: failed to open Excel file [name=%s]: %w", name, err)
+	}
+	return newExcelTemplate(name, f), nil
+}
+
+// newExcelTemplate 使用默认配置创建Excel模板渲染器
+func newExcelTemplate(templatePath string, f *excelize.File) *ExcelTemplate {
+	return &ExcelTemplate{
 		TemplatePath:  templatePath,
 		File:          f,
 		SheetCache:    make(map[string]*SheetCache),
 		FormulaEngine: NewSimpleFormulaEngine(),
 		ListField:     "table",
 	}
-	return et, nil
 }
 
 func parseMergeCells(mergeCells []excelize.MergeCell) []MergeRange {
@@ -154,29 +268,75 @@ type RenderOptions struct {
 
 // Render 渲染Excel模板
 func (et *ExcelTemplate) Render(data any) (*excelize.File, error) {
+	return et.RenderContext(context.Background(), data)
+}
+
+// Prepare 解析所有工作表的配置、列信息和样式并缓存到 SheetCache，随后删除配置行和配置列。
+// 渲染时不再重复解析，可以在渲染前通过 SheetCache 查看模板结构
+func (et *ExcelTemplate) Prepare() error {
+	for _, sheet := range et.File.GetSheetList() {
+		if _, ok := et.SheetCache[sheet]; ok {
+			continue
+		}
+		err := et.prepareSheet(sheet)
+		if err != nil {
+			return fmt.Errorf("Prepare: failed to prepare sheet [sheet=%s]: %w", sheet, err)
+		}
+	}
+	return nil
+}
+
+// RenderContext 渲染Excel模板，ctx 取消或超时后在下一行数据或下一个sheet开始前停止渲染并返回 ctx.Err()。
+// data 可以是 map[string]any、结构体或其指针；直接传入切片时作为 ListField 对应的列表数据；
+// 传入 SheetData 时按工作表名称分别绑定数据。
+// 单元格渲染失败时返回的错误中包含 RenderError；CollectErrors 模式下同时返回渲染结果和 RenderErrors
+func (et *ExcelTemplate) RenderContext(ctx context.Context, data any) (*excelize.File, error) {
+	et.renderErrors = nil
+	sheetData, ok := asSheetData(data)
+	if !ok {
+		sheetData = &SheetData{Global: data}
+	}
+	globalData := et.toFillData(sheetData.Global)
+	//流式写出后的工作表无法再读取，需要提前清除公式缓存
+	if et.Streaming {
+		et.File.UpdateLinkedValue()
+	}
 	//遍历所有的sheet
-	for i, sheet := range et.File.GetSheetList() {
-		et.SheetCache[sheet] = &SheetCache{
-			Config:      make(map[string][][]string),
-			ColumnList:  make([]*Column, 0, 3),
-			StartRowNum: 0,
-		}
-		var ok bool
-		et.SheetCache[sheet].FillData, ok = data.(map[string]any)
-		if ok {
-			listData, ok := data.([]map[string]any)
-			if ok && len(listData) > i {
-				et.SheetCache[sheet].FillData = listData[i]
+	for _, sheet := range et.File.GetSheetList() {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("Render: render canceled [sheet=%s]: %w", sheet, err)
+		}
+		// 通过 CompiledTemplate 渲染时工作表已经预解析
+		if _, ok := et.SheetCache[sheet]; !ok {
+			err := et.prepareSheet(sheet)
+			if err != nil {
+				return nil, fmt.Errorf("Render: failed to prepare sheet [sheet=%s]: %w", sheet, err)
 			}
 		}
-		err := et.processSheet(sheet)
+		et.SheetCache[sheet].FillData = globalData
+		if data, ok := sheetData.Sheets[sheet]; ok {
+			et.SheetCache[sheet].FillData = et.toFillData(data)
+		}
+		if et.SheetCache[sheet].Clone != nil {
+			err := et.cloneSheet(ctx, sheet)
+			if err != nil {
+				return nil, fmt.Errorf("Render: failed to clone sheet [sheet=%s]: %w", sheet, err)
+			}
+			continue
+		}
+		err := et.processSheet(ctx, sheet)
 		if err != nil {
 			return nil, fmt.Errorf("Render: failed to process sheet [sheet=%s]: %w", sheet, err)
 		}
 	}
 
 	//更新公式缓存
-	et.File.UpdateLinkedValue()
+	if !et.Streaming {
+		et.File.UpdateLinkedValue()
+	}
+	if len(et.renderErrors) > 0 {
+		return et.File, et.renderErrors
+	}
 	return et.File, nil
 }
 
@@ -222,45 +382,138 @@ func (et *ExcelTemplate) fillRows(mergeRanges []MergeRange, rows [][]string) [][
 	return rows
 }
 
-// processSheet 处理单个sheet的数据
-func (et *ExcelTemplate) processSheet(sheet string) error {
+// prepareSheet 解析单个sheet的配置、列信息和样式并缓存到 SheetCache，随后删除配置行和配置列。
+// 解析结果与填充数据无关，CompiledTemplate 只需执行一次
+func (et *ExcelTemplate) prepareSheet(sheet string) error {
+	et.SheetCache[sheet] = &SheetCache{
+		Blocks: make([]*TableBlock, 0, 1),
+	}
 	// 获取基础数据
 	rows, mergeCells, err := et.getSheetData(sheet)
 	if err != nil {
-		return fmt.Errorf("processSheet: failed to get sheet data [sheet=%s]: %w", sheet, err)
+		return fmt.Errorf("prepareSheet: failed to get sheet data [sheet=%s]: %w", sheet, err)
 	}
-
-	// 处理模板语法
-	err = et.processTemplates(sheet, rows)
+	keywords, err := et.sheetKeywords(rows)
 	if err != nil {
-		return fmt.Errorf("processSheet: failed to process templates [sheet=%s]: %w", sheet, err)
+		return fmt.Errorf("prepareSheet: failed to resolve keywords [sheet=%s]: %w", sheet, err)
 	}
+	et.SheetCache[sheet].Keywords = keywords
+
+	// 记录模板语法单元格，渲染时再填充
+	templateCells := et.findTemplateCells(keywords, rows)
 	mergeRanges := parseMergeCells(mergeCells)
 	et.SheetCache[sheet].MergeRanges = mergeRanges
 	rows = et.fillRows(mergeRanges, rows)
+	// 配置列中的关键字统一转换为关键字名称
+	normalizeConfigNames(keywords, rows)
 
-	// 处理配置和列信息
-	config := make(map[string][][]string)
-	columns := make([]*Column, 0, 3)
-	fillRowNum := 0
+	// 处理配置和列信息，每个表头配置行开始一个新的列表区域，第一个表头之前的配置行属于第一个列表区域
+	blocks := make([]*TableBlock, 0, 1)
+	var block *TableBlock
 	configRowNums := make([]int, 0, 1)
+	blockDataRowNums := make([][]int, 0, 1)
+	// 列表区域所属的分组区域，不在分组区域内时为 nil
+	blockSections := make([]*Section, 0, 1)
+	sections := make([]*Section, 0)
+	var section *Section
 
 	for rowIndex, row := range rows {
 		//如果不是配置列，则跳过
-		if len(row) == 0 || row[0] == "" || !lo.Contains(configKeys, row[0]) {
+		if len(row) == 0 || row[0] == "" || !lo.Contains(constant.Names, row[0]) {
 			continue
 		}
 
 		configName := row[0]
+		rowNum := rowIndex + 1
+		//分组配置行划定重复的模板区域，区域内的配置行属于新的列表区域
+		if configName == constant.KeySection || configName == constant.KeySectionEnd {
+			configRowNums = append(configRowNums, rowNum)
+			block = nil
+			if configName == constant.KeySectionEnd {
+				if section != nil {
+					section.EndRowNum = rowNum
+				}
+				section = nil
+				continue
+			}
+			if section != nil {
+				return fmt.Errorf("prepareSheet: nested section is not supported [sheet=%s, row=%d]", sheet, rowNum)
+			}
+			listField, _ := lo.Find(row[1:], func(item string) bool {
+				return item != ""
+			})
+			section = &Section{ListField: listField, StartRowNum: rowNum}
+			sections = append(sections, section)
+			continue
+		}
+		//复制工作表配置：第2列为列表字段，第3列为工作表名称模板
+		if configName == constant.KeyCloneSheet {
+			configRowNums = append(configRowNums, rowNum)
+			clone := &CloneSheet{}
+			if len(row) > 1 {
+				clone.ListField = row[1]
+			}
+			if len(row) > 2 {
+				clone.SheetName = row[2]
+			}
+			et.SheetCache[sheet].Clone = clone
+			continue
+		}
+		//横向列表配置：列表字段写在需要重复的列中，相邻且列表字段相同的列作为一组重复；
+		//动态列配置：列表字段所在的列按数据定义的列重复，每个元素一列
+		if configName == constant.KeyHorizontalList || configName == constant.KeyDynamicColumns {
+			configRowNums = append(configRowNums, rowNum)
+			dynamic := configName == constant.KeyDynamicColumns
+			for colIndex, listField := range row {
+				if colIndex == 0 || listField == "" {
+					continue
+				}
+				// 删除配置列后的列号
+				colNum := colIndex
+				hls := et.SheetCache[sheet].HorizontalLists
+				if !dynamic && len(hls) > 0 && !hls[len(hls)-1].Dynamic && hls[len(hls)-1].ListField == listField && hls[len(hls)-1].EndCol == colNum-1 {
+					hls[len(hls)-1].EndCol = colNum
+					continue
+				}
+				et.SheetCache[sheet].HorizontalLists = append(hls, &HorizontalList{ListField: listField, StartCol: colNum, EndCol: colNum, Dynamic: dynamic})
+			}
+			continue
+		}
+		if block == nil || (configName == constant.KeyHeader && block.StartRowNum != 0) {
+			block = &TableBlock{
+				Config:     make(map[string][][]string),
+				ColumnList: make([]*Column, 0, 3),
+			}
+			blocks = append(blocks, block)
+			blockDataRowNums = append(blockDataRowNums, make([]int, 0, 2))
+			blockSections = append(blockSections, section)
+		}
+		config := block.Config
+		columns := block.ColumnList
+		// 数据行的单元格可能都是空的，补齐到已解析的列数，保证每列都能读取到样式
+		if configName == constant.KeyData && len(columns) > 0 {
+			for len(row) < columns[len(columns)-1].ColNum {
+				row = append(row, "")
+			}
+		}
 		if config[configName] == nil {
 			config[configName] = make([][]string, 0, 1)
 		}
 		config[configName] = append(config[configName], row)
-		rowNum := rowIndex + 1
 		configRowNums = append(configRowNums, rowNum)
 
-		if configName == constant.Header {
-			fillRowNum = rowNum + 1
+		if configName == constant.KeyHeader {
+			block.StartRowNum = rowNum + 1
+		}
+		if configName == constant.KeyData {
+			blockDataRowNums[len(blocks)-1] = append(blockDataRowNums[len(blocks)-1], rowNum)
+		}
+		//列表字段配置只取第一个非空值，不参与列解析
+		if configName == constant.KeyList {
+			block.ListField, _ = lo.Find(row[1:], func(item string) bool {
+				return item != ""
+			})
+			continue
 		}
 
 		for colIndex, col := range row {
@@ -271,55 +524,60 @@ func (et *ExcelTemplate) processSheet(sheet string) error {
 			value := col
 			cellName, err := excelize.CoordinatesToCellName(colNum, rowNum)
 			if err != nil {
-				return fmt.Errorf("processSheet: failed to convert coordinates to cell name [sheet=%s, row=%d, col=%d]: %w", sheet, rowNum, colNum, err)
+				return fmt.Errorf("prepareSheet: failed to convert coordinates to cell name [sheet=%s, row=%d, col=%d]: %w", sheet, rowNum, colNum, err)
 			}
 
-			// if ContainsGoTemplateSyntax(value) {
-			// 	value, err = RenderTemplate(value, et.SheetCache[sheet].FillData, et.FuncMap)
-			// 	if err != nil {
-			// 		fmt.Println(cellName, value)
-			// 		panic(err)
-			// 	}
-			// 	et.File.SetCellValue(sheet, cellName, value)
-			// }
-
 			column, ok := lo.Find(columns, func(column *Column) bool {
 				return column != nil && column._key == colNum
 			})
 
 			if ok {
 				switch configName {
-				case constant.DataField:
+				case constant.KeyDataField:
 					column.DataField = value
 					column.IsTemplate = ContainsGoTemplateSyntax(value)
-				case constant.BackgroundColor:
+				case constant.KeyBackgroundColor:
 					column.BackgroundColorExpr = value
-				case constant.FontColor:
+				case constant.KeyFontColor:
 					column.FontColorExpr = value
-				case constant.Data:
+				case constant.KeyDateLayout:
+					column.DateLayout = value
+				case constant.KeyNumberFormat:
+					column.NumberFormat = value
+				case constant.KeyColumnWidth:
+					column.Width, column.AutoWidth, err = parseColumnWidth(value)
+					if err != nil {
+						return fmt.Errorf("prepareSheet: invalid column width [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+					}
+				case constant.KeyDefault:
+					column.DefaultValue, err = et.readDefaultValue(sheet, cellName, value)
+					if err != nil {
+						return fmt.Errorf("prepareSheet: failed to read default value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+					}
+				case constant.KeyData:
 					if column.CellList == nil {
 						column.CellList = make([]*ColumnCell, 0, 1)
 					}
 					columnCell := ColumnCell{}
-					et.SheetCache[sheet].DataRowHeight, err = et.File.GetRowHeight(sheet, rowNum)
+					block.DataRowHeight, err = et.File.GetRowHeight(sheet, rowNum)
 					if err != nil {
-						return fmt.Errorf("processSheet: failed to get row height [sheet=%s, row=%d]: %w", sheet, rowNum, err)
+						return fmt.Errorf("prepareSheet: failed to get row height [sheet=%s, row=%d]: %w", sheet, rowNum, err)
 					}
 
 					columnCell._key = rowNum
 					columnCell.Formula, err = et.File.GetCellFormula(sheet, cellName)
 					if err != nil {
-						return fmt.Errorf("processSheet: failed to get cell formula [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+						return fmt.Errorf("prepareSheet: failed to get cell formula [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 					}
 
 					//设置样式
 					columnCell.StyleId, err = et.File.GetCellStyle(sheet, cellName)
 					if err != nil {
-						return fmt.Errorf("processSheet: failed to get cell style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+						return fmt.Errorf("prepareSheet: failed to get cell style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 					}
 					columnCell.Style, err = et.File.GetStyle(columnCell.StyleId)
 					if err != nil {
-						return fmt.Errorf("processSheet: failed to get style details [sheet=%s, styleId=%d]: %w", sheet, columnCell.StyleId, err)
+						return fmt.Errorf("prepareSheet: failed to get style details [sheet=%s, styleId=%d]: %w", sheet, columnCell.StyleId, err)
 					}
 					//清空公式，因为带公式的话后续RemoveRow会报错
 					et.File.SetCellFormula(sheet, cellName, "")
@@ -342,75 +600,312 @@ func (et *ExcelTemplate) processSheet(sheet string) error {
 
 				colName, err := excelize.ColumnNumberToName(colNum)
 				if err != nil {
-					return fmt.Errorf("processSheet: failed to convert column number to name [sheet=%s, col=%d]: %w", sheet, colNum, err)
+					return fmt.Errorf("prepareSheet: failed to convert column number to name [sheet=%s, col=%d]: %w", sheet, colNum, err)
 				}
 				column.ColName = colName
 
 				renderColName, err := excelize.ColumnNumberToName(column.RenderColNum)
 				if err != nil {
-					return fmt.Errorf("processSheet: failed to convert render column number to name [sheet=%s, col=%d]: %w", sheet, column.RenderColNum, err)
+					return fmt.Errorf("prepareSheet: failed to convert render column number to name [sheet=%s, col=%d]: %w", sheet, column.RenderColNum, err)
 				}
 				column.RenderColName = renderColName
 
 				columns = append(columns, &column)
 			}
 		}
-
-		// 缓存配置
-		et.SheetCache[sheet].Config = config
-		et.SheetCache[sheet].ColumnList = columns
-		et.SheetCache[sheet].StartRowNum = fillRowNum
+		block.ColumnList = columns
 	}
 
-	if len(columns) == 0 {
-		return nil
+	//没有列定义的列表区域不渲染
+	keepRowNums := make([]int, 0, 3*len(blocks))
+	for i, block := range blocks {
+		if len(block.ColumnList) == 0 {
+			continue
+		}
+		//表头留1行 数据留2行 这样如果有公式的话会自动更新，其余配置行全部删除
+		dataRowNums := blockDataRowNums[i][:min(len(blockDataRowNums[i]), 2)]
+		keepRowNums = append(keepRowNums, block.StartRowNum-1)
+		keepRowNums = append(keepRowNums, dataRowNums...)
+		block.TemplateDataRows = len(dataRowNums)
+		block.Defaults = blockDefaults(block.ColumnList)
+		if blockSections[i] != nil {
+			blockSections[i].Blocks = append(blockSections[i].Blocks, block)
+			continue
+		}
+		et.SheetCache[sheet].Blocks = append(et.SheetCache[sheet].Blocks, block)
+	}
+	if section != nil {
+		return fmt.Errorf("prepareSheet: section is not closed [sheet=%s, row=%d]", sheet, section.StartRowNum)
+	}
+	if len(keepRowNums) == 0 && len(sections) == 0 && et.SheetCache[sheet].Clone == nil && len(et.SheetCache[sheet].HorizontalLists) == 0 {
+		return et.setTemplateCells(sheet, templateCells, nil, 0)
 	}
 
-	//表头留1行 数据留2行 这样如果有公式的话会自动更新
-	for i := len(configRowNums) - 1; i > 2; i-- {
-		// fmt.Println("remove row", configRowNums[i])
+	removedRowNums := make([]int, 0, len(configRowNums))
+	for i := len(configRowNums) - 1; i >= 0; i-- {
+		if lo.Contains(keepRowNums, configRowNums[i]) {
+			continue
+		}
 		et.File.RemoveRow(sheet, configRowNums[i])
+		removedRowNums = append(removedRowNums, configRowNums[i])
 	}
 	et.File.RemoveCol(sheet, "A")
+	removedAbove := func(row int) int {
+		return lo.CountBy(removedRowNums, func(rowNum int) bool {
+			return rowNum < row
+		})
+	}
+	for _, block := range blocks {
+		block.StartRowNum -= removedAbove(block.StartRowNum)
+	}
+	err = et.setTemplateCells(sheet, templateCells, removedRowNums, 1)
+	if err != nil {
+		return err
+	}
 
-	table, ok := et.SheetCache[sheet].FillData[et.ListField]
-	if !ok {
-		return nil
+	//分组配置行已删除，区域为两个分组配置行之间剩余的行，没有剩余行的分组区域不渲染
+	for _, section := range sections {
+		section.StartRowNum, section.EndRowNum = section.StartRowNum-removedAbove(section.StartRowNum), section.EndRowNum-removedAbove(section.EndRowNum)-1
+		if section.EndRowNum < section.StartRowNum {
+			continue
+		}
+		// 分组区域内的模板单元格按元素数据渲染
+		section.TemplateCells, et.SheetCache[sheet].TemplateCells = lo.FilterReject(et.SheetCache[sheet].TemplateCells, func(templateCell TemplateCell, _ int) bool {
+			_, row, _ := excelize.CellNameToCoordinates(templateCell.CellName)
+			return row >= section.StartRowNum && row <= section.EndRowNum
+		})
+		et.SheetCache[sheet].Sections = append(et.SheetCache[sheet].Sections, section)
+	}
+	return nil
+}
+
+// sheetKeywords 返回工作表使用的关键字字典，未指定语言时按配置列中的表头文字识别
+func (et *ExcelTemplate) sheetKeywords(rows [][]string) (constant.Keywords, error) {
+	language := et.Language
+	if language == "" {
+		texts := lo.FilterMap(rows, func(row []string, _ int) (string, bool) {
+			return lo.FirstOr(row, ""), len(row) > 0
+		})
+		language = lo.CoalesceOrEmpty(constant.DetectLanguage(texts), constant.DefaultLanguage)
 	}
-	list, ok := table.([]map[string]any)
+	keywords, ok := constant.GetKeywords(language)
 	if !ok {
-		tableList, ok := table.([]any)
-		if ok {
-			list = lo.Map(tableList, func(item any, index int) map[string]any {
-				return item.(map[string]any)
-			})
+		return nil, fmt.Errorf("sheetKeywords: unknown language [language=%s]", language)
+	}
+	return keywords, nil
+}
+
+// normalizeConfigNames 将配置列中的关键字替换为关键字名称，其他内容保持不变
+func normalizeConfigNames(keywords constant.Keywords, rows [][]string) {
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		if name, ok := keywords.Lookup(row[0], constant.Names); ok {
+			row[0] = name
+		} else if lo.Contains(constant.Names, row[0]) {
+			// 其他语言的关键字名称不是当前语言的配置
+			row[0] = ""
 		}
 	}
+}
 
-	if len(list) == 0 {
-		return nil
+// findTemplateCells 查找含有模板语法的单元格，数据字段行中的模板按数据行渲染，不在此列
+func (et *ExcelTemplate) findTemplateCells(keywords constant.Keywords, rows [][]string) []TemplateCell {
+	templateCells := make([]TemplateCell, 0)
+	for i, row := range rows {
+		if len(row) > 0 && row[0] == keywords[constant.KeyDataField] {
+			continue
+		}
+		for j, col := range row {
+			if ContainsGoTemplateSyntax(col) {
+				cellName, _ := excelize.CoordinatesToCellName(j+1, i+1)
+				templateCells = append(templateCells, TemplateCell{CellName: cellName, Template: col})
+			}
+		}
 	}
+	return templateCells
+}
 
-	// 处理分类汇总
-	list = et.handleSubtotal(config, list, fillRowNum)
+// setTemplateCells 将模板单元格的位置换算为删除配置行列之后的位置并缓存，位于被删除行列中的单元格直接丢弃
+func (et *ExcelTemplate) setTemplateCells(sheet string, templateCells []TemplateCell, removedRowNums []int, removedColNum int) error {
+	result := make([]TemplateCell, 0, len(templateCells))
+	for _, templateCell := range templateCells {
+		col, row, err := excelize.CellNameToCoordinates(templateCell.CellName)
+		if err != nil {
+			return fmt.Errorf("setTemplateCells: failed to parse cell name [sheet=%s, cell=%s]: %w", sheet, templateCell.CellName, err)
+		}
+		if col <= removedColNum || lo.Contains(removedRowNums, row) {
+			continue
+		}
+		removedAbove := lo.CountBy(removedRowNums, func(rowNum int) bool {
+			return rowNum < row
+		})
+		cellName, err := excelize.CoordinatesToCellName(col-removedColNum, row-removedAbove)
+		if err != nil {
+			return fmt.Errorf("setTemplateCells: failed to convert coordinates to cell name [sheet=%s, cell=%s]: %w", sheet, templateCell.CellName, err)
+		}
+		result = append(result, TemplateCell{CellName: cellName, Template: templateCell.Template})
+	}
+	et.SheetCache[sheet].TemplateCells = result
+	return nil
+}
 
-	// 插入数据行
-	et.File.InsertRows(sheet, fillRowNum+1, len(list)-2)
+// processSheet 使用填充数据渲染单个sheet
+func (et *ExcelTemplate) processSheet(ctx context.Context, sheet string) error {
+	// 展开横向列表，之后的渲染使用展开后的列信息
+	err := et.expandColumns(sheet)
+	if err != nil {
+		return fmt.Errorf("processSheet: failed to expand columns [sheet=%s]: %w", sheet, err)
+	}
 
-	// 处理数据填充
-	err = et.processData(sheet, list)
+	// 处理模板语法
+	err = et.processTemplates(sheet)
 	if err != nil {
-		return fmt.Errorf("processSheet: failed to process data [sheet=%s]: %w", sheet, err)
+		return fmt.Errorf("processSheet: failed to process templates [sheet=%s]: %w", sheet, err)
+	}
+
+	// 分组区域需要复制模板行，不支持流式写出
+	cache := et.SheetCache[sheet]
+	if et.Streaming && len(cache.Sections) == 0 {
+		return et.streamSheet(ctx, sheet)
 	}
 
-	// 设置自动筛选
-	et.setAutoFilter(sheet, len(list))
+	et.startProgress(sheet, et.sheetRowCount(sheet))
+	// 列表区域和分组区域从下往上渲染，插入的数据行不会改变上方区域的位置，下方已渲染的内容由 InsertRows 整体下移
+	blocks, sections := cache.Blocks, cache.Sections
+	rendered := false
+	for i, j := len(blocks)-1, len(sections)-1; i >= 0 || j >= 0; {
+		if j >= 0 && (i < 0 || sections[j].StartRowNum > blocks[i].StartRowNum) {
+			err = et.renderSection(ctx, sheet, sections[j])
+			if err != nil {
+				return fmt.Errorf("processSheet: failed to render section [sheet=%s, row=%d]: %w", sheet, sections[j].StartRowNum, err)
+			}
+			rendered = true
+			j--
+			continue
+		}
+		block := blocks[i]
+		listLen, err := et.renderBlock(ctx, sheet, block, cache.FillData)
+		if err != nil {
+			return fmt.Errorf("processSheet: failed to process data [sheet=%s, row=%d]: %w", sheet, block.StartRowNum, err)
+		}
+		// 工作表只能设置一个自动筛选，只作用于第一个列表区域
+		if i == 0 && listLen > 0 {
+			et.setAutoFilter(sheet, block, listLen)
+		}
+		rendered = rendered || listLen > 0
+		i--
+	}
+	et.reportProgress()
+	err = et.fixConditionalColors(sheet)
+	if err != nil {
+		return fmt.Errorf("processSheet: %w", err)
+	}
+	if !rendered {
+		return nil
+	}
 
 	et.File.SetSheetProps(sheet, et.SheetPropsOptions)
 	et.File.SetPageLayout(sheet, et.PageLayoutOptions)
 	return nil
 }
 
+// renderBlock 插入数据行并填充列表区域，返回包含分类汇总行在内的数据行数
+func (et *ExcelTemplate) renderBlock(ctx context.Context, sheet string, block *TableBlock, fillData map[string]any) (int, error) {
+	list := et.blockList(sheet, block, fillData)
+	if len(list) == 0 {
+		return 0, nil
+	}
+	fillRowNum := block.StartRowNum
+
+	// 插入数据行
+	et.File.InsertRows(sheet, fillRowNum+1, len(list)-block.TemplateDataRows)
+
+	// 先设置列宽，自动换行的数据行按列宽计算行高
+	widths := et.blockColumnWidths(sheet, block, list, fillRowNum)
+	for col, width := range widths {
+		colName, _ := excelize.ColumnNumberToName(col)
+		err := et.File.SetColWidth(sheet, colName, colName, width)
+		if err != nil {
+			return 0, fmt.Errorf("renderBlock: failed to set column width [sheet=%s, col=%s]: %w", sheet, colName, err)
+		}
+	}
+
+	err := et.setConditionalColors(sheet, block, fillRowNum, list)
+	if err != nil {
+		return 0, err
+	}
+
+	// 处理数据填充
+	err = et.processData(ctx, sheet, block, list, widths)
+	if err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
+
+// blockList 返回列表区域写入的数据，包括分类汇总行
+func (et *ExcelTemplate) blockList(sheet string, block *TableBlock, fillData map[string]any) []map[string]any {
+	list := et.getBlockList(block, fillData)
+	if len(list) == 0 {
+		return nil
+	}
+	return et.handleSubtotal(et.SheetCache[sheet].Keywords, block, list, block.StartRowNum)
+}
+
+// getBlockList 获取列表区域绑定的列表数据，未配置列表字段时使用 ExcelTemplate.ListField
+func (et *ExcelTemplate) getBlockList(block *TableBlock, fillData map[string]any) []map[string]any {
+	if len(block.ColumnList) == 0 || block.TemplateDataRows == 0 {
+		return nil
+	}
+	listField := block.ListField
+	if listField == "" {
+		listField = et.ListField
+	}
+	return getList(fillData, listField)
+}
+
+// ItemValueField 横向列表中不是 map 的元素（如 "1月"）在元素数据中的字段名，模板中使用 {{.value}} 引用
+const ItemValueField = "value"
+
+// getList 从填充数据中获取列表，支持 []map[string]any 和 []any，[]any 中不是 map[string]any 的元素（包括 null）跳过
+func getList(fillData map[string]any, listField string) []map[string]any {
+	return listItems(fillData, listField, false)
+}
+
+// getItemList 与 getList 相同，但 []any 中的数字、文字等元素放在 ItemValueField 字段中，用于横向列表
+func getItemList(fillData map[string]any, listField string) []map[string]any {
+	return listItems(fillData, listField, true)
+}
+
+func listItems(fillData map[string]any, listField string, wrapScalar bool) []map[string]any {
+	table, ok := fillData[listField]
+	if !ok {
+		return nil
+	}
+	if list, ok := table.([]map[string]any); ok {
+		return list
+	}
+	tableList, ok := table.([]any)
+	if !ok {
+		return nil
+	}
+	list := make([]map[string]any, 0, len(tableList))
+	for _, item := range tableList {
+		switch item := item.(type) {
+		case map[string]any:
+			list = append(list, item)
+		case nil:
+		default:
+			if wrapScalar {
+				list = append(list, map[string]any{ItemValueField: item})
+			}
+		}
+	}
+	return list
+}
+
 // getSheetData 获取sheet的基础数据
 func (et *ExcelTemplate) getSheetData(sheet string) ([][]string, []excelize.MergeCell, error) {
 	rows, err := et.File.GetRows(sheet)
@@ -425,33 +920,62 @@ func (et *ExcelTemplate) getSheetData(sheet string) ([][]string, []excelize.Merg
 }
 
 // processTemplates 处理模板语法
-func (et *ExcelTemplate) processTemplates(sheet string, rows [][]string) error {
-	fillData := et.SheetCache[sheet].FillData
-	for i, row := range rows {
-		if len(row) > 0 && row[0] == constant.DataField {
-			continue
+func (et *ExcelTemplate) processTemplates(sheet string) error {
+	return et.renderTemplateCells(sheet, et.SheetCache[sheet].TemplateCells, et.SheetCache[sheet].FillData, 0)
+}
+
+// renderTemplateCells 使用 fillData 渲染模板单元格，rowOffset 为单元格相对缓存位置下移的行数
+func (et *ExcelTemplate) renderTemplateCells(sheet string, templateCells []TemplateCell, fillData map[string]any, rowOffset int) error {
+	for _, templateCell := range templateCells {
+		cellName := templateCell.CellName
+		data := fillData
+		if templateCell.Data != nil {
+			data = mergeFillData(fillData, templateCell.Data)
 		}
-		for j, col := range row {
-			if ContainsGoTemplateSyntax(col) {
-				value, err := RenderTemplate(col, fillData, et.FuncMap)
-				if err != nil {
-					return fmt.Errorf("processTemplates: failed to render template [sheet=%s, row=%d, col=%d]: %w", sheet, i+1, j+1, err)
-				}
-				cellName, err := excelize.CoordinatesToCellName(j+1, i+1)
-				if err != nil {
-					return fmt.Errorf("processTemplates: failed to convert coordinates to cell name [sheet=%s, row=%d, col=%d]: %w", sheet, i+1, j+1, err)
-				}
-				et.setCellData(sheet, cellName, value)
+		if rowOffset != 0 {
+			col, row, err := excelize.CellNameToCoordinates(cellName)
+			if err != nil {
+				return fmt.Errorf("renderTemplateCells: failed to parse cell name [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+			}
+			cellName, _ = excelize.CoordinatesToCellName(col, row+rowOffset)
+		}
+		value := ""
+		data, err := et.fillMissingFields(data, templateFieldNames(templateCell.Template), "")
+		if err == nil {
+			value, err = RenderTemplate(templateCell.Template, data, et.FuncMap)
+		}
+		if err != nil {
+			err = &RenderError{Sheet: sheet, Cell: cellName, Row: -1, Field: templateCell.Template, Phase: PhaseTemplate, Err: err}
+			if err = et.handleCellError(err); err != nil {
+				return fmt.Errorf("renderTemplateCells: failed to render template [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 			}
+			continue
+		}
+		style, err := et.getCellStyle(sheet, cellName)
+		if err != nil {
+			return fmt.Errorf("renderTemplateCells: failed to get cell style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+		}
+		cellValue, formula := templateCellValue(templateCell.Template, value, data, style, et.TimeZone)
+		if formula != "" {
+			err = et.File.SetCellFormula(sheet, cellName, formula)
+		} else {
+			err = et.setCellData(sheet, cellName, cellValue)
+		}
+		if err = et.handleCellError(locateRenderError(err, sheet, cellName, -1)); err != nil {
+			return fmt.Errorf("renderTemplateCells: failed to set cell value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 		}
 	}
 	return nil
 }
 
-// processData 处理数据填充
-func (et *ExcelTemplate) processData(sheet string, list []map[string]any) error {
+// processData 处理数据填充，widths 为列表区域修改后的列宽
+func (et *ExcelTemplate) processData(ctx context.Context, sheet string, block *TableBlock, list []map[string]any, widths map[int]float64) error {
 	for i := range list {
-		err := et.processDataRow(sheet, i, list[i])
+		err := et.checkRowProgress(ctx, sheet, i)
+		if err != nil {
+			return fmt.Errorf("processData: %w", err)
+		}
+		err = et.processDataRow(sheet, block, i, list[i], widths)
 		if err != nil {
 			return fmt.Errorf("processData: failed to process data row [sheet=%s, row=%d]: %w", sheet, i, err)
 		}
@@ -459,18 +983,23 @@ func (et *ExcelTemplate) processData(sheet string, list []map[string]any) error
 	return nil
 }
 
-func (et *ExcelTemplate) processDataRow(sheet string, listIndex int, rowData map[string]any) error {
-	columns := et.SheetCache[sheet].ColumnList
-	fillRowNum := et.SheetCache[sheet].StartRowNum
+func (et *ExcelTemplate) processDataRow(sheet string, block *TableBlock, listIndex int, rowData map[string]any, widths map[int]float64) error {
+	columns := block.ColumnList
+	fillRowNum := block.StartRowNum
 	rowNum := fillRowNum + listIndex
 	formulaResultCache := make(map[string]any)
 	styleIdCache := make(map[string]int)
-	et.File.SetRowHeight(sheet, rowNum, et.SheetCache[sheet].DataRowHeight)
+	et.File.SetRowHeight(sheet, rowNum, block.DataRowHeight)
 	isSubtotal := rowData["_row_type"] == "subtotal"
 	_listIndex := listIndex
 	if _, ok := rowData["_row_index"]; ok {
 		_listIndex = rowData["_row_index"].(int)
 	}
+	if !isSubtotal {
+		rowData = et.fillDefaults(rowData, block.Defaults)
+	}
+	// 自动换行的单元格显示全部文字需要的行高
+	fitHeight := 0.0
 	for _, column := range columns {
 		cellName := fmt.Sprintf("%s%d", column.RenderColName, rowNum)
 		if column.IsMergeCell {
@@ -491,8 +1020,9 @@ func (et *ExcelTemplate) processDataRow(sheet string, listIndex int, rowData map
 				return fmt.Errorf("processDataRow: failed to merge [sheet=%s, cell=%s:%s]: %w", sheet, topLeftCell, bottomRightCell, err)
 			}
 		}
-		err := et.processCellData(sheet, cellName, column, _listIndex, rowNum, rowData, isSubtotal)
-		if err != nil {
+		cellData, cellFormulaCache := column.cellData(rowData, formulaResultCache)
+		value, err := et.processCellData(sheet, cellName, column, _listIndex, rowNum, cellData, isSubtotal)
+		if err = et.handleCellError(locateRenderError(err, sheet, cellName, listIndex)); err != nil {
 			return fmt.Errorf("processDataRow: failed to set cell value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 		}
 
@@ -501,18 +1031,22 @@ func (et *ExcelTemplate) processDataRow(sheet string, listIndex int, rowData map
 			continue
 		}
 
-		err = et.applyCellStyle(sheet, formulaResultCache, styleIdCache, cellName, column, _listIndex, rowData)
-		if err != nil {
+		err = et.applyCellStyle(sheet, cellFormulaCache, styleIdCache, cellName, column, _listIndex, cellData, value)
+		if err = et.handleCellError(locateRenderError(err, sheet, cellName, listIndex)); err != nil {
 			return fmt.Errorf("processDataRow: failed to apply cell style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 		}
+		fitHeight = max(fitHeight, et.wrapTextHeight(sheet, column, _listIndex, value, widths))
+	}
+	if fitHeight > 0 {
+		et.File.SetRowHeight(sheet, rowNum, et.fitRowHeight(block.DataRowHeight, fitHeight))
 	}
 	return nil
 }
 
 // setAutoFilter 设置自动筛选
-func (et *ExcelTemplate) setAutoFilter(sheet string, listLen int) {
-	columns := et.SheetCache[sheet].ColumnList
-	fillRowNum := et.SheetCache[sheet].StartRowNum
+func (et *ExcelTemplate) setAutoFilter(sheet string, block *TableBlock, listLen int) {
+	columns := block.ColumnList
+	fillRowNum := block.StartRowNum
 	if len(columns) == 0 {
 		return
 	}
@@ -525,20 +1059,114 @@ func (et *ExcelTemplate) setAutoFilter(sheet string, listLen int) {
 }
 
 // applyCellStyle 处理单元格样式设置，包括背景色和字体颜色
-func (et *ExcelTemplate) applyCellStyle(sheet string, formulaResultCache map[string]any, styleIdCache map[string]int, cellName string, column *Column, listIndex int, rowData map[string]any) error {
+func (et *ExcelTemplate) applyCellStyle(sheet string, formulaResultCache map[string]any, styleIdCache map[string]int, cellName string, column *Column, listIndex int, rowData map[string]any, value any) error {
+	styleId, err := et.resolveCellStyle(sheet, formulaResultCache, styleIdCache, cellName, column, listIndex, rowData, value)
+	if err != nil {
+		return err
+	}
+	et.File.SetCellStyle(sheet, cellName, cellName, styleId)
+	return nil
+}
+
+// resolveCellStyle 计算数据单元格最终使用的样式Id，按需根据背景色、字体颜色表达式和数字格式创建新样式，
+// 值为时间且样式不是日期格式时改用日期格式
+func (et *ExcelTemplate) resolveCellStyle(sheet string, formulaResultCache map[string]any, styleIdCache map[string]int, cellName string, column *Column, listIndex int, rowData map[string]any, value any) (int, error) {
+	styleId, err := et.resolveColorStyle(sheet, formulaResultCache, styleIdCache, cellName, column, listIndex, rowData)
+	if err != nil {
+		return 0, err
+	}
+	styleId, err = et.numberFormatStyle(formulaResultCache, styleId, column, listIndex, rowData)
+	if err != nil {
+		return 0, err
+	}
+	if t, ok := value.(time.Time); ok {
+		return et.timeStyle(styleId, t)
+	}
+	return styleId, nil
+}
+
+// timeStyle 返回写入时间的单元格使用的样式Id，原样式已经是日期格式时保持不变
+func (et *ExcelTemplate) timeStyle(styleId int, t time.Time) (int, error) {
+	key := [2]int{styleId, timeNumFmt(t)}
+	if timeStyleId, ok := et.timeStyles[key]; ok {
+		return timeStyleId, nil
+	}
+	style, err := et.File.GetStyle(styleId)
+	if err != nil {
+		return 0, fmt.Errorf("timeStyle: failed to get style [styleId=%d]: %w", styleId, err)
+	}
+	timeStyleId := styleId
+	if styleNumFmtKind(style) != numFmtDate {
+		style.NumFmt = key[1]
+		style.CustomNumFmt = nil
+		timeStyleId, err = et.File.NewStyle(style)
+		if err != nil {
+			return 0, fmt.Errorf("timeStyle: failed to create style [styleId=%d]: %w", styleId, err)
+		}
+	}
+	if et.timeStyles == nil {
+		et.timeStyles = make(map[[2]int]int)
+	}
+	et.timeStyles[key] = timeStyleId
+	return timeStyleId, nil
+}
+
+// numberFormatStyle 按数字格式配置返回单元格样式Id，以 = 开头的配置按行计算格式代码，结果为空时保持原样式
+func (et *ExcelTemplate) numberFormatStyle(formulaResultCache map[string]any, styleId int, column *Column, listIndex int, rowData map[string]any) (int, error) {
+	code := column.NumberFormat
+	if strings.HasPrefix(code, "=") {
+		data, err := et.fillMissingFields(rowData, formulaVariables(code), nil)
+		if err != nil {
+			return 0, &RenderError{Field: code, Phase: PhaseStyle, Err: err}
+		}
+		result, err := et.getFormulaResult(formulaResultCache, listIndex, code, data)
+		if err != nil {
+			return 0, &RenderError{Field: code, Phase: PhaseStyle, Err: fmt.Errorf("failed to calculate number format: %w", err)}
+		}
+		code, _ = result.(string)
+	}
+	if code == "" {
+		return styleId, nil
+	}
+	key := fmt.Sprintf("%d-%s", styleId, code)
+	if numFmtStyleId, ok := et.numFmtStyles[key]; ok {
+		return numFmtStyleId, nil
+	}
+	style, err := et.File.GetStyle(styleId)
+	if err != nil {
+		return 0, fmt.Errorf("numberFormatStyle: failed to get style [styleId=%d]: %w", styleId, err)
+	}
+	style.NumFmt = 0
+	style.CustomNumFmt = &code
+	numFmtStyleId, err := et.File.NewStyle(style)
+	if err != nil {
+		return 0, fmt.Errorf("numberFormatStyle: failed to create style [styleId=%d, format=%s]: %w", styleId, code, err)
+	}
+	if et.numFmtStyles == nil {
+		et.numFmtStyles = make(map[string]int)
+	}
+	et.numFmtStyles[key] = numFmtStyleId
+	return numFmtStyleId, nil
+}
+
+// resolveColorStyle 按背景色和字体颜色表达式计算单元格样式Id
+func (et *ExcelTemplate) resolveColorStyle(sheet string, formulaResultCache map[string]any, styleIdCache map[string]int, cellName string, column *Column, listIndex int, rowData map[string]any) (int, error) {
 	idx := listIndex % len(column.CellList)
 	dataProp := column.CellList[idx]
-	et.File.SetCellStyle(sheet, cellName, cellName, dataProp.StyleId)
 
-	if len(column.BackgroundColorExpr) == 0 && len(column.FontColorExpr) == 0 {
-		return nil
+	if len(column.BackgroundColorExpr) == 0 && len(column.FontColorExpr) == 0 || et.conditionalColumns[column] {
+		return dataProp.StyleId, nil
 	}
 
 	var bgColor = ""
 	if column.BackgroundColorExpr != "" && column.BackgroundColorExpr[0] == '=' {
-		result, err := et.getFormulaResult(formulaResultCache, listIndex, column.BackgroundColorExpr, rowData)
+		data, err := et.fillMissingFields(rowData, formulaVariables(column.BackgroundColorExpr), nil)
+		if err != nil {
+			return 0, &RenderError{Field: column.BackgroundColorExpr, Phase: PhaseStyle, Err: err}
+		}
+		result, err := et.getFormulaResult(formulaResultCache, listIndex, column.BackgroundColorExpr, data)
 		if err != nil {
-			return fmt.Errorf("applyCellStyle: failed to calculate background color formula [sheet=%s, cell=%s, expr=%s]: %w", sheet, cellName, column.BackgroundColorExpr, err)
+			return 0, &RenderError{Field: column.BackgroundColorExpr, Phase: PhaseStyle, Err: fmt.Errorf("failed to calculate background color: %w", err)}
 		}
 		if result != nil {
 			bgColor, _ = result.(string)
@@ -546,114 +1174,142 @@ func (et *ExcelTemplate) applyCellStyle(sheet string, formulaResultCache map[str
 	}
 	var fontColor = ""
 	if column.FontColorExpr != "" && column.FontColorExpr[0] == '=' {
-		result, err := et.getFormulaResult(formulaResultCache, listIndex, column.FontColorExpr, rowData)
+		data, err := et.fillMissingFields(rowData, formulaVariables(column.FontColorExpr), nil)
+		if err != nil {
+			return 0, &RenderError{Field: column.FontColorExpr, Phase: PhaseStyle, Err: err}
+		}
+		result, err := et.getFormulaResult(formulaResultCache, listIndex, column.FontColorExpr, data)
 		if err != nil {
-			return fmt.Errorf("applyCellStyle: failed to calculate font color formula [sheet=%s, cell=%s, expr=%s]: %w", sheet, cellName, column.FontColorExpr, err)
+			return 0, &RenderError{Field: column.FontColorExpr, Phase: PhaseStyle, Err: fmt.Errorf("failed to calculate font color: %w", err)}
 		}
 		if result != nil {
 			fontColor, _ = result.(string)
 		}
 	}
 	if bgColor == "" && fontColor == "" {
-		return nil
+		return dataProp.StyleId, nil
 	}
 
 	styleKey := fmt.Sprintf("%d-%s-%s", dataProp.StyleId, bgColor, fontColor)
-	styleId, ok := styleIdCache[styleKey]
-	if ok {
-		et.File.SetCellStyle(sheet, cellName, cellName, styleId)
-	} else {
-		style := &excelize.Style{}
-		deepcopy.Copy(style, dataProp.Style)
-		if bgColor != "" {
-			style.Fill.Type = "pattern"
-			style.Fill.Pattern = 1
-			style.Fill.Color = []string{bgColor}
-		}
-		if fontColor != "" {
-			style.Font.Color = fontColor
-			style.Font.ColorTheme = nil
-		}
-		styleId, err := et.File.NewStyle(style)
-		if err != nil {
-			return fmt.Errorf("applyCellStyle: failed to create new style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
-		}
-		styleIdCache[styleKey] = styleId
-		et.File.SetCellStyle(sheet, cellName, cellName, styleId)
+	if styleId, ok := styleIdCache[styleKey]; ok {
+		return styleId, nil
 	}
-	return nil
+	style := &excelize.Style{}
+	deepcopy.Copy(style, dataProp.Style)
+	if bgColor != "" {
+		style.Fill.Type = "pattern"
+		style.Fill.Pattern = 1
+		style.Fill.Color = []string{bgColor}
+	}
+	if fontColor != "" {
+		style.Font.Color = fontColor
+		style.Font.ColorTheme = nil
+	}
+	styleId, err := et.File.NewStyle(style)
+	if err != nil {
+		return 0, fmt.Errorf("applyCellStyle: failed to create new style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+	}
+	styleIdCache[styleKey] = styleId
+	return styleId, nil
 }
 
 // processCellData 处理单元格数据设置，包括小计行和普通数据行，支持图片自动插入
-func (et *ExcelTemplate) processCellData(sheet string, cellName string, column *Column, listIndex int, rowNum int, rowData map[string]any, isSubtotal bool) error {
-	idx := listIndex % len(column.CellList)
-	itemData, ok := rowData[column.DataField]
-	//如果是分类汇总字段
+func (et *ExcelTemplate) processCellData(sheet string, cellName string, column *Column, listIndex int, rowNum int, rowData map[string]any, isSubtotal bool) (any, error) {
+	value, formula, err := et.resolveCellData(sheet, cellName, column, listIndex, rowNum, rowData, isSubtotal)
+	if err != nil {
+		return nil, err
+	}
+	//如果是分类汇总字段，先清空模板行残留的值
 	if isSubtotal {
 		v, err := et.File.GetCellValue(sheet, cellName)
 		if err != nil {
-			return fmt.Errorf("processCellData: failed to get cell value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+			return nil, fmt.Errorf("processCellData: failed to get cell value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
 		}
 		if v != "" {
 			err = et.setCellData(sheet, cellName, "")
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
-		if ok {
-			valueStr, ok := itemData.(string)
-			// fmt.Println("valueStr", itemData, valueStr, column.DataField)
-			if ok && len(valueStr) > 1 {
-				if valueStr[0] == '=' {
-					et.File.SetCellFormula(sheet, cellName, valueStr[1:])
-				} else {
-					err = et.setCellData(sheet, cellName, itemData)
-					if err != nil {
-						return err
-					}
-				}
-			}
+	}
+	if formula != "" {
+		et.File.SetCellFormula(sheet, cellName, formula)
+		return nil, nil
+	}
+	if isSubtotal && value == nil {
+		return nil, nil
+	}
+	return value, et.setCellData(sheet, cellName, value)
+}
+
+// resolveCellData 计算数据单元格的值或公式，不直接写入文件
+func (et *ExcelTemplate) resolveCellData(sheet string, cellName string, column *Column, listIndex int, rowNum int, rowData map[string]any, isSubtotal bool) (any, string, error) {
+	idx := listIndex % len(column.CellList)
+	itemData, ok := rowData[column.DataField]
+	//如果是分类汇总字段
+	if isSubtotal {
+		if !ok {
+			return nil, "", nil
 		}
-		return nil
+		valueStr, ok := itemData.(string)
+		if !ok || len(valueStr) <= 1 {
+			return nil, "", nil
+		}
+		if valueStr[0] == '=' {
+			return nil, valueStr[1:], nil
+		}
+		return itemData, "", nil
 	}
 
 	dataProp := column.CellList[idx]
 	//如果是公式
 	if dataProp.Formula != "" {
-		var newFormula = ReplaceFormulaRow(dataProp.Formula, rowNum, -1)
-		et.File.SetCellFormula(sheet, cellName, newFormula)
-		return nil
+		formula, err := replaceFormulaRow(dataProp.Formula, rowNum, -1)
+		if err != nil {
+			return nil, "", &RenderError{Field: dataProp.Formula, Phase: PhaseFormula, Err: err}
+		}
+		return nil, formula, nil
 	}
 	//如果字段使用了模板语法
 	if column.IsTemplate {
-		value, err := RenderTemplate(column.DataField, rowData, et.FuncMap)
+		data, err := et.fillMissingFields(rowData, templateFieldNames(column.DataField), "")
 		if err != nil {
-			return fmt.Errorf("processCellData: failed to render template field [sheet=%s, cell=%s, field=%s]: %w", sheet, cellName, column.DataField, err)
+			return nil, "", &RenderError{Field: column.DataField, Phase: PhaseTemplate, Err: err}
 		}
-		err = et.setCellData(sheet, cellName, value)
+		value, err := RenderTemplate(column.DataField, data, et.FuncMap)
 		if err != nil {
-			return err
+			return nil, "", &RenderError{Field: column.DataField, Phase: PhaseTemplate, Err: err}
 		}
-		return nil
+		cellValue, formula := templateCellValue(column.DataField, value, data, dataProp.Style, et.TimeZone)
+		return cellValue, formula, nil
+	}
+	if !ok && column.DataField != "" && et.MissingFields == MissingFieldError {
+		return nil, "", &RenderError{Field: column.DataField, Phase: PhaseData, Err: fmt.Errorf("%w [field=%s]", ErrMissingField, column.DataField)}
+	}
+	// 按列的日期解析格式将文本转换为时间
+	if str, ok := itemData.(string); ok && str != "" && column.DateLayout != "" {
+		t, err := parseTime(column.DateLayout, str, et.TimeZone)
+		if err != nil {
+			return nil, "", &RenderError{Field: column.DataField, Phase: PhaseData, Err: err}
+		}
+		itemData = t
 	}
-	// dataConfigValue := column.CellList[idx].Data
-	// if dataConfigValue != "" {
-	// 	fmt.Println("dataConfigValue", cellName, dataConfigValue)
-	// 	if len(dataConfigValue) > 0 && dataConfigValue[0] == '=' {
-	// 		value, _, err := et.FormulaEngine.EvalFormula(dataConfigValue, rowData)
-	// 		if err != nil {
-	// 			panic(err)
-	// 		}
-	// 		et.File.SetCellValue(sheet, cellName, value)
-	// 	} else {
-	// 		et.File.SetCellValue(sheet, cellName, dataConfigValue)
-	// 	}
-	// }
-	err := et.setCellData(sheet, cellName, itemData)
+	if t, ok := itemData.(time.Time); ok {
+		if t.IsZero() {
+			return nil, "", nil
+		}
+		return cellTime(t, et.TimeZone), "", nil
+	}
+	return itemData, "", nil
+}
+
+// getCellStyle 返回单元格的样式详情
+func (et *ExcelTemplate) getCellStyle(sheet string, cellName string) (*excelize.Style, error) {
+	styleId, err := et.File.GetCellStyle(sheet, cellName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return et.File.GetStyle(styleId)
 }
 
 // setCellData 包装了 SetCellValue，当值是图片数据时自动插入图片
@@ -671,7 +1327,7 @@ func (et *ExcelTemplate) setCellData(sheet, cellName string, value any) error {
 		ext, imageData, config, err := ProcessImageData(strValue)
 		if err != nil {
 			// 解析失败，仍然设置为文本值
-			return et.File.SetCellValue(sheet, cellName, err.Error())
+			return et.imageError(sheet, cellName, err)
 		}
 		col, row, err := excelize.CellNameToCoordinates(cellName)
 		if err != nil {
@@ -768,7 +1424,7 @@ func (et *ExcelTemplate) setCellData(sheet, cellName string, value any) error {
 
 		if err != nil {
 			// 添加图片失败，设置为文本值
-			return et.File.SetCellValue(sheet, cellName, err.Error())
+			return et.imageError(sheet, cellName, err)
 		}
 
 		return nil
@@ -778,44 +1434,48 @@ func (et *ExcelTemplate) setCellData(sheet, cellName string, value any) error {
 	return et.File.SetCellValue(sheet, cellName, value)
 }
 
-// handleSubtotal 处理数据的分类汇总
-func (et *ExcelTemplate) handleSubtotal(config map[string][][]string, list []map[string]any, fillRowNum int) []map[string]any {
-	if rows, ok := config[constant.Subtotal]; ok {
+// handleSubtotal 处理数据的分类汇总，分类汇总行的文字使用 keywords 生成
+func (et *ExcelTemplate) handleSubtotal(keywords constant.Keywords, block *TableBlock, list []map[string]any, fillRowNum int) []map[string]any {
+	config := block.Config
+	if rows, ok := config[constant.KeySubtotal]; ok {
 		for _, row := range rows {
 			_, groupByIdex, gOk := lo.FindIndexOf(row, func(item string) bool {
-				return item == "分类"
+				return item == keywords[constant.KeyGroup]
 			})
 			if !gOk {
 				continue
 			}
-			groupKey := config[constant.DataField][0][groupByIdex]
+			groupKey := config[constant.KeyDataField][0][groupByIdex]
 
 			// 查找所有汇总操作列
 			for colIndex, colValue := range row {
-				if colIndex == 0 || colValue == "" || colValue == "分类" {
+				if colIndex == 0 || colValue == "" || colValue == keywords[constant.KeyGroup] {
 					continue
 				}
 
 				// 查找匹配的汇总函数
-				subtotal, found := lo.Find(Subtotals, func(item Subtotal) bool {
-					return item.Func == colValue
-				})
-
+				name, _ := keywords.Lookup(colValue, constant.SubtotalFuncs)
+				subtotal, found := subtotalOf(keywords, name)
 				if !found {
 					continue // 如果没有找到匹配的汇总函数，跳过
 				}
 
 				// 获取对应的数据字段
-				subtotalKey := config[constant.DataField][0][colIndex]
+				subtotalKey := config[constant.KeyDataField][0][colIndex]
 				if subtotalKey == "" {
 					continue
 				}
 
-				// 获取列名
+				// 获取列名，横向列表展开后按模板中的列查找渲染后的列名
 				subtotalCellLetter, err := excelize.ColumnNumberToName(colIndex)
 				if err != nil {
 					continue
 				}
+				if column, ok := lo.Find(block.ColumnList, func(column *Column) bool {
+					return column._key == colIndex+1
+				}); ok {
+					subtotalCellLetter = column.RenderColName
+				}
 
 				// 执行汇总操作
 				list = GroupAndSubtotal(list, groupKey, subtotalKey, subtotalCellLetter, fillRowNum, subtotal)
@@ -826,7 +1486,7 @@ func (et *ExcelTemplate) handleSubtotal(config map[string][][]string, list []map
 			// 	return item == "求和"
 			// })
 			// if sOk {
-			// 	subtotalKey := config[constant.DataField][0][subtotalIndex]
+			// 	subtotalKey := config[constant.KeyDataField][0][subtotalIndex]
 			// 	subtotalCellLetter, _ := excelize.ColumnNumberToName(subtotalIndex)
 			// 	subtotal, _ := lo.Find(Subtotals, func(item Subtotal) bool {
 			// 		return item.Func == "求和"
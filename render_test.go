@@ -1,8 +1,12 @@
 package excel_template
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"math/rand"
 	"os"
 	"strings"
@@ -12,7 +16,10 @@ import (
 	"time"
 
 	"runtime/pprof"
+	"sort"
 
+	"github.com/mzzya/excel_template/constant"
+	"github.com/samber/lo"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -158,7 +165,8 @@ func TestExcelize(t *testing.T) {
 			for i := range taskCh {
 				err := f.SetCellValue("Sheet1", fmt.Sprintf("A%d", i+1), fmt.Sprintf("Value %d", i+1))
 				if err != nil {
-					t.Fatal(err)
+					t.Error(err)
+					return
 				}
 			}
 		}()
@@ -175,3 +183,1775 @@ func TestExcelize(t *testing.T) {
 	}
 	t.Logf("程序运行时间：%s", time.Since(startTime))
 }
+
+func TestRenderStreaming(t *testing.T) {
+	render := func(streaming bool) *excelize.File {
+		var fillData = make(map[string]any)
+		data := make([]map[string]any, 0, 50)
+		for i := range 50 {
+			row := generateRandomData(i + 1)
+			// 只有前两行插入图片
+			if i >= 2 {
+				row["条形码"] = ""
+			}
+			data = append(data, row)
+		}
+		fillData["table"] = data
+		fillData["总金额"] = 10000000
+		fillData["对账日期"] = "2025年04月28日"
+		fillData["生成日期"] = "2025-04-28"
+
+		et, err := OpenFile("template/template.xlsx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+		et.Streaming = streaming
+		f, err := et.Render(fillData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 流式写出的工作表需要保存后重新打开才能读取
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	normal, streamed := render(false), render(true)
+
+	for _, sheet := range normal.GetSheetList() {
+		normalRows, _ := normal.GetRows(sheet)
+		streamedRows, _ := streamed.GetRows(sheet)
+		if len(normalRows) != len(streamedRows) {
+			t.Fatalf("工作表 %s 行数不一致: %d != %d", sheet, len(normalRows), len(streamedRows))
+		}
+	}
+	for _, cell := range []string{"H14", "H15", "J7", "J30"} {
+		normalFormula, _ := normal.GetCellFormula("Sheet1", cell)
+		streamedFormula, _ := streamed.GetCellFormula("Sheet1", cell)
+		if normalFormula != streamedFormula {
+			t.Errorf("Sheet1!%s 公式不一致: %s != %s", cell, normalFormula, streamedFormula)
+		}
+	}
+	// 条形码列中的图片数据在两种模式下都插入为图片
+	for cell, want := range map[string]int{"G6": 1, "G7": 1, "G8": 0} {
+		for mode, f := range map[string]*excelize.File{"normal": normal, "streaming": streamed} {
+			if pictures, _ := f.GetPictures("Sheet1", cell); len(pictures) != want {
+				t.Errorf("%s Sheet1!%s 期望 %d 张图片，实际 %d", mode, cell, want, len(pictures))
+			}
+		}
+	}
+}
+
+func TestRenderStreamingSectionFallback(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"分组": {
+			{"分组", "customers"},
+			{"", "客户：{{.客户名称}}"},
+			{"表头", "订单号"},
+			{"数据", ""},
+			{"列表", "orders"},
+			{"数据字段", "订单号"},
+			{"分组结束"},
+		},
+		"明细": {
+			{"表头", "订单号"},
+			{"数据", ""},
+			{"数据字段", "订单号"},
+		},
+	})
+	et.Streaming = true
+	f, err := et.Render(map[string]any{
+		"customers": []map[string]any{{"客户名称": "A", "orders": []map[string]any{{"订单号": "O1"}}}},
+		"table":     []map[string]any{{"订单号": "O2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = excelize.OpenReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 包含分组区域的工作表使用普通模式渲染，其他工作表仍然流式写出
+	for sheet, want := range map[string]string{
+		"分组": "[[客户：A] [订单号] [O1]]",
+		"明细": "[[订单号] [O2]]",
+	} {
+		if rows, _ := f.GetRows(sheet); fmt.Sprint(rows) != want {
+			t.Errorf("%s 期望 %s，实际 %v", sheet, want, rows)
+		}
+	}
+}
+
+func TestRenderStreamingParity(t *testing.T) {
+	render := func(streaming bool) *excelize.File {
+		// 分类汇总会修改列表数据，每次渲染使用新的数据
+		data := make([]map[string]any, 0, 20)
+		for i := range 20 {
+			data = append(data, map[string]any{
+				"订单号":  fmt.Sprintf("order%03d", i),
+				"客户名称": []string{"张三", "李四", "王五"}[i%3],
+				"公司名称": "宏李四网络技术有限公司",
+				"含税金额": float64(i*100) + 0.5,
+				"未税金额": float64(i * 90),
+				"是否签收": []string{"是", "否"}[i%2],
+				"数量":   i * 7,
+				"下单时间": "2025-04-28 10:00:00",
+				"签收时间": "2025-04-29",
+			})
+		}
+		et, err := OpenFile("template/template.xlsx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+		et.Streaming = streaming
+		f, err := et.Render(map[string]any{"table": data, "总金额": 100, "对账日期": "2025年04月28日", "生成日期": "2025-04-28"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	normal, streamed := render(false), render(true)
+
+	for _, sheet := range normal.GetSheetList() {
+		rows, _ := normal.GetRows(sheet)
+		cols, _ := normal.GetCols(sheet)
+		for rowNum := 1; rowNum <= len(rows); rowNum++ {
+			for colNum := 1; colNum <= len(cols); colNum++ {
+				cellName, _ := excelize.CoordinatesToCellName(colNum, rowNum)
+				normalValue, _ := normal.GetCellValue(sheet, cellName, excelize.Options{RawCellValue: true})
+				streamedValue, _ := streamed.GetCellValue(sheet, cellName, excelize.Options{RawCellValue: true})
+				normalFormula, _ := normal.GetCellFormula(sheet, cellName)
+				streamedFormula, _ := streamed.GetCellFormula(sheet, cellName)
+				if normalValue != streamedValue || normalFormula != streamedFormula {
+					t.Errorf("%s!%s 不一致: %q=%q != %q=%q", sheet, cellName, normalFormula, normalValue, streamedFormula, streamedValue)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderStreamingTrailingDataRows(t *testing.T) {
+	// 模板数据行为空且位于工作表末尾
+	sheets := map[string][][]any{
+		"订单": {
+			{"表头", "单号"},
+			{"数据", ""},
+			{"数据", ""},
+			{"数据字段", "单号"},
+		},
+	}
+	for _, count := range []int{1, 3} {
+		render := func(streaming bool) *excelize.File {
+			et := newTestTemplate(t, sheets)
+			et.Streaming = streaming
+			// 未使用的模板数据行保留行高
+			et.File.SetRowHeight("订单", 3, 30)
+			list := make([]map[string]any, 0, count)
+			for i := range count {
+				list = append(list, map[string]any{"单号": fmt.Sprintf("A%d", i+1)})
+			}
+			f, err := et.Render(map[string]any{"table": list})
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf, err := f.WriteToBuffer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err = excelize.OpenReader(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return f
+		}
+		normal, streamed := render(false), render(true)
+		normalRows, _ := normal.GetRows("订单")
+		streamedRows, _ := streamed.GetRows("订单")
+		if len(normalRows) != count+1 || fmt.Sprint(normalRows) != fmt.Sprint(streamedRows) {
+			t.Errorf("%d 行数据流式写出结果不一致: %v != %v", count, normalRows, streamedRows)
+		}
+		normalHeight, _ := normal.GetRowHeight("订单", 3)
+		streamedHeight, _ := streamed.GetRowHeight("订单", 3)
+		if normalHeight != streamedHeight {
+			t.Errorf("%d 行数据第 3 行行高不一致: %v != %v", count, normalHeight, streamedHeight)
+		}
+	}
+}
+
+func TestOpenFromSources(t *testing.T) {
+	content, err := os.ReadFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	openers := map[string]func() (*ExcelTemplate, error){
+		"reader": func() (*ExcelTemplate, error) { return OpenReader(bytes.NewReader(content)) },
+		"bytes":  func() (*ExcelTemplate, error) { return OpenBytes(content) },
+		"fs":     func() (*ExcelTemplate, error) { return OpenFS(os.DirFS("template"), "template.xlsx") },
+	}
+	for name, open := range openers {
+		et, err := open()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if et.TemplatePath == "" || et.ListField != "table" || et.FormulaEngine == nil {
+			t.Errorf("%s: 默认配置未初始化 %+v", name, et)
+		}
+		if len(et.File.GetSheetList()) != 3 {
+			t.Errorf("%s: 工作表数量不正确 %v", name, et.File.GetSheetList())
+		}
+	}
+	if _, err := OpenFS(os.DirFS("template"), "missing.xlsx"); err == nil {
+		t.Error("打开不存在的模板应返回错误")
+	}
+}
+
+func TestCompiledTemplateConcurrentRender(t *testing.T) {
+	ct, err := CompileFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := make([]map[string]any, 0, 10+worker)
+			for i := range 10 + worker {
+				row := generateRandomData(i + 1)
+				row["条形码"] = ""
+				data = append(data, row)
+			}
+			f, err := ct.Render(map[string]any{"table": data, "总金额": worker, "生成日期": "2025-04-28"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rows, err := f.GetRows("Sheet3")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			// Sheet3: 表头 + 数据行 + 分类汇总行 + 总计行 + 空行 + 2行合计
+			if rows[0][0] != "测试集团" || rows[1][0] != "订单号" || len(rows) < len(data)+5 {
+				t.Errorf("worker %d: 渲染结果不正确 %d 行", worker, len(rows))
+			}
+			total, _ := f.GetCellValue("Sheet2", "F2")
+			if total != fmt.Sprint(worker) {
+				t.Errorf("worker %d: 总金额为 %s", worker, total)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// fixedFormulaEngine 总是返回同一结果的公式引擎
+type fixedFormulaEngine struct {
+	value string
+}
+
+func (e fixedFormulaEngine) EvalFormula(formulaExpr string, data map[string]any) (string, any, error) {
+	return e.value, e.value, nil
+}
+
+func TestCompileFormulaEngine(t *testing.T) {
+	newTemplate := func() *ExcelTemplate {
+		return newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "单号"},
+				{"数据", ""},
+				{"数据字段", "单号"},
+				{"背景色", `=IF(单号="A001","ffff00","")`},
+			},
+		})
+	}
+	// 自定义公式引擎没有创建函数时不能编译
+	et := newTemplate()
+	et.FormulaEngine = fixedFormulaEngine{value: "FF0000"}
+	if _, err := et.Compile(); err == nil || !strings.Contains(err.Error(), "NewFormulaEngine") {
+		t.Fatalf("期望返回需要 NewFormulaEngine 的错误，实际 %v", err)
+	}
+
+	et = newTemplate()
+	et.FormulaEngine = fixedFormulaEngine{value: "FF0000"}
+	et.NewFormulaEngine = func() FormulaEngine { return fixedFormulaEngine{value: "FF0000"} }
+	ct, err := et.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ct.Render(map[string]any{"table": []map[string]any{{"单号": "A001"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	styleID, _ := f.GetCellStyle("订单", "A2")
+	style, _ := f.GetStyle(styleID)
+	if len(style.Fill.Color) != 1 || style.Fill.Color[0] != "FF0000" {
+		t.Errorf("期望使用自定义公式引擎的背景色 FF0000，实际 %v", style.Fill.Color)
+	}
+}
+
+func TestRenderContext(t *testing.T) {
+	newFillData := func() map[string]any {
+		data := make([]map[string]any, 0, 250)
+		for i := range 250 {
+			row := generateRandomData(i + 1)
+			row["条形码"] = ""
+			data = append(data, row)
+		}
+		return map[string]any{"table": data}
+	}
+
+	et, err := OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	ctx, cancel := context.WithCancel(context.Background())
+	et.OnProgress = func(progress RenderProgress) {
+		// 第一个sheet写入一部分后取消
+		if progress.Sheet == "Sheet1" && progress.RowsDone >= 100 {
+			cancel()
+		}
+	}
+	_, err = et.RenderContext(ctx, newFillData())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望返回 context.Canceled，实际 %v", err)
+	}
+
+	et, err = OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	finished := make(map[string]RenderProgress)
+	et.OnProgress = func(progress RenderProgress) {
+		if progress.RowsDone == progress.TotalRows {
+			finished[progress.Sheet] = progress
+		}
+	}
+	_, err = et.RenderContext(context.Background(), newFillData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(finished) != 3 || finished["Sheet1"].TotalRows != 250 {
+		t.Errorf("进度报告不完整: %+v", finished)
+	}
+}
+
+func TestRenderProgressAcrossBlocks(t *testing.T) {
+	sheets := map[string][][]any{
+		"订单": {
+			{"表头", "单号"},
+			{"数据", ""},
+			{"列表", "a"},
+			{"数据字段", "单号"},
+			{"表头", "单号"},
+			{"数据", ""},
+			{"列表", "b"},
+			{"数据字段", "单号"},
+		},
+		"客户": {
+			{"分组", "customers"},
+			{"表头", "单号"},
+			{"数据", ""},
+			{"列表", "orders"},
+			{"数据字段", "单号"},
+			{"分组结束"},
+		},
+	}
+	rows := func(count int) []map[string]any {
+		list := make([]map[string]any, 0, count)
+		for i := range count {
+			list = append(list, map[string]any{"单号": i})
+		}
+		return list
+	}
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, sheets)
+		et.Streaming = streaming
+		progress := make(map[string][]string)
+		et.OnProgress = func(p RenderProgress) {
+			progress[p.Sheet] = append(progress[p.Sheet], fmt.Sprintf("%d/%d", p.RowsDone, p.TotalRows))
+		}
+		_, err := et.Render(map[string]any{
+			"a":         rows(150),
+			"b":         rows(120),
+			"customers": []map[string]any{{"orders": rows(60)}, {"orders": rows(70)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 进度按工作表累计所有列表区域
+		want := map[string][]string{
+			"订单": {"100/270", "200/270", "270/270"},
+			"客户": {"100/130", "130/130"},
+		}
+		if fmt.Sprint(progress) != fmt.Sprint(want) {
+			t.Errorf("streaming=%v 期望 %v，实际 %v", streaming, want, progress)
+		}
+	}
+}
+
+type testOrderBase struct {
+	OrderNo string `excel:"订单号"`
+	Company string `json:"公司名称,omitempty"`
+}
+
+type testOrder struct {
+	testOrderBase
+	Customer  string  `excel:"客户名称"`
+	CostCode  string  `excel:"成本中心"`
+	Signed    string  `excel:"是否签收"`
+	Quantity  int     `json:"数量"`
+	TaxAmount float64 `excel:"含税金额"`
+	NetAmount float64 `excel:"未税金额"`
+	OrderTime string  `excel:"下单时间"`
+	SignTime  *string `excel:"签收时间"`
+	Internal  string  `excel:"-"`
+}
+
+type testStatement struct {
+	Orders    []*testOrder `excel:"table"`
+	Total     int          `excel:"总金额"`
+	CheckDate string       `excel:"对账日期"`
+}
+
+func TestRenderStruct(t *testing.T) {
+	signTime := "2025-04-28"
+	orders := make([]*testOrder, 0, 6)
+	for i := range 6 {
+		orders = append(orders, &testOrder{
+			testOrderBase: testOrderBase{OrderNo: fmt.Sprintf("order%03d", i), Company: "宏李四网络技术有限公司"},
+			Customer:      []string{"张三", "李四"}[i%2],
+			Signed:        []string{"是", "否"}[i%2],
+			Quantity:      i * 10,
+			TaxAmount:     float64(i),
+			SignTime:      &signTime,
+		})
+	}
+	et, err := OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	f, err := et.Render(&testStatement{Orders: orders, Total: 100, CheckDate: "2025年04月28日"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Sheet1!A6": "ORDER000",    // {{.订单号 | toUpper}}
+		"Sheet1!B6": "宏李四网络技术有限公司", // json 标签
+		"Sheet2!F2": "100",         // {{.总金额}}
+		"Sheet2!A6": "order000",
+		"Sheet2!F6": "0",
+		"Sheet2!E6": "2025-04-28",
+	}
+	for ref, want := range expected {
+		sheet, cell, _ := strings.Cut(ref, "!")
+		got, _ := f.GetCellValue(sheet, cell)
+		if got != want {
+			t.Errorf("%s 期望 %q，实际 %q", ref, want, got)
+		}
+	}
+	// 背景色表达式使用结构体字段作为公式变量
+	styleId, _ := f.GetCellStyle("Sheet2", "C6")
+	style, _ := f.GetStyle(styleId)
+	if len(style.Fill.Color) == 0 || !strings.EqualFold(style.Fill.Color[0], "ffff00") {
+		t.Errorf("Sheet2!C6 背景色未生效: %+v", style.Fill)
+	}
+
+	// 直接传入结构体切片时作为列表数据
+	et, err = OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	f, err = et.Render(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sheet3 按客户名称分类汇总，第2行是同组的 order002
+	if got, _ := f.GetCellValue("Sheet3", "A4"); got != "order002" {
+		t.Errorf("Sheet3!A4 期望 order002，实际 %q", got)
+	}
+}
+
+func TestNormalizeData(t *testing.T) {
+	// JSON 解码的对象不重建，对象组成的数组转换为列表数据
+	var decoded map[string]any
+	json.Unmarshal([]byte(`{"标题":"对账单","table":[{"单号":"A1"},{"单号":"A2"}]}`), &decoded)
+	first := decoded["table"].([]any)[0].(map[string]any)
+	fillData, ok := normalizeData(decoded).(map[string]any)
+	if !ok {
+		t.Fatalf("转换结果应为 map[string]any: %T", normalizeData(decoded))
+	}
+	list, ok := fillData["table"].([]map[string]any)
+	if !ok || len(list) != 2 {
+		t.Fatalf("对象数组应转换为列表数据: %T", fillData["table"])
+	}
+	list[0]["标记"] = true
+	if first["标记"] != true {
+		t.Error("列表中的对象不应被复制")
+	}
+
+	// 只复制包含结构体的部分，不修改传入的数据
+	labels := []string{"a", "b"}
+	data := map[string]any{"标签": labels, "订单": testOrder{Customer: "张三"}}
+	normalized := normalizeData(data).(map[string]any)
+	if _, ok := data["订单"].(testOrder); !ok {
+		t.Error("不应修改传入的数据")
+	}
+	if order, ok := normalized["订单"].(map[string]any); !ok || order["客户名称"] != "张三" {
+		t.Errorf("结构体应转换为 map[string]any: %v", normalized["订单"])
+	}
+	if _, ok := normalized["标签"].([]string); !ok {
+		t.Errorf("标量切片应保持不变: %T", normalized["标签"])
+	}
+}
+
+func TestRenderSheetData(t *testing.T) {
+	newList := func(n int) []map[string]any {
+		data := make([]map[string]any, 0, n)
+		for i := range n {
+			row := generateRandomData(i + 1)
+			row["条形码"] = ""
+			data = append(data, row)
+		}
+		return data
+	}
+	et, err := OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	f, err := et.Render(SheetData{
+		Sheets: map[string]any{
+			"Sheet1": map[string]any{"table": newList(3), "生成日期": "2025-04-28"},
+			"Sheet2": map[string]any{"table": newList(5), "总金额": 500},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// H2 为日期格式，按日期序列号写入
+	if got, _ := f.GetCellValue("Sheet1", "H2", excelize.Options{RawCellValue: true}); got != "45775" {
+		t.Errorf("Sheet1!H2 期望 2025-04-28 的序列号 45775，实际 %q", got)
+	}
+	if got, _ := f.GetCellValue("Sheet2", "F2"); got != "500" {
+		t.Errorf("Sheet2!F2 期望 500，实际 %q", got)
+	}
+	// Sheet3 没有数据，只保留表头和模板数据行
+	if got, _ := f.GetCellValue("Sheet3", "A3"); got != "" {
+		t.Errorf("Sheet3!A3 期望为空，实际 %q", got)
+	}
+
+	et, err = OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.FuncMap = template.FuncMap{"toUpper": strings.ToUpper}
+	f, err = et.Render(&SheetData{
+		Global: map[string]any{"table": newList(2), "总金额": 1},
+		Sheets: map[string]any{"Sheet2": map[string]any{"table": newList(4), "总金额": 400}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.GetCellValue("Sheet2", "F2"); got != "400" {
+		t.Errorf("Sheet2!F2 期望 400，实际 %q", got)
+	}
+	if got, _ := f.GetCellValue("Sheet3", "A3"); got == "" {
+		t.Error("Sheet3 应使用 Global 数据填充")
+	}
+}
+
+// newTestTemplate 根据行数据在内存中创建模板，key 为工作表名称，每行第一列为配置列
+func newTestTemplate(t *testing.T, sheets map[string][][]any) *ExcelTemplate {
+	t.Helper()
+	f := excelize.NewFile()
+	names := make([]string, 0, len(sheets))
+	for name := range sheets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i == 0 {
+			f.SetSheetName("Sheet1", name)
+		} else if _, err := f.NewSheet(name); err != nil {
+			t.Fatal(err)
+		}
+		for rowIndex, row := range sheets[name] {
+			cell, _ := excelize.CoordinatesToCellName(1, rowIndex+1)
+			if err := f.SetSheetRow(name, cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err := OpenBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return et
+}
+
+func TestRenderListFieldConfig(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"订单": {
+			{"", "订单"},
+			{"表头", "订单号", "金额"},
+			{"数据", "", ""},
+			{"数据", "", ""},
+			{"列表", "orders"},
+			{"数据字段", "订单号", "金额"},
+			{"", "合计"},
+		},
+		"退款": {
+			// 列表行在表头之前
+			{"列表", "refunds"},
+			{"表头", "退款单号"},
+			{"数据", ""},
+			{"数据字段", "退款单号"},
+		},
+		"发票": {
+			{"表头", "发票号"},
+			{"数据", ""},
+			{"数据字段", "发票号"},
+		},
+	})
+	f, err := et.Render(map[string]any{
+		"orders":  []map[string]any{{"订单号": "O1", "金额": 1}, {"订单号": "O2", "金额": 2}, {"订单号": "O3", "金额": 3}},
+		"refunds": []map[string]any{{"退款单号": "R1"}, {"退款单号": "R2"}},
+		"table":   []map[string]any{{"发票号": "I1"}, {"发票号": "I2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][][]string{
+		"订单": {{"订单"}, {"订单号", "金额"}, {"O1", "1"}, {"O2", "2"}, {"O3", "3"}, {"合计"}},
+		"退款": {{"退款单号"}, {"R1"}, {"R2"}},
+		"发票": {{"发票号"}, {"I1"}, {"I2"}},
+	}
+	for sheet, want := range expected {
+		rows, _ := f.GetRows(sheet)
+		if fmt.Sprint(rows) != fmt.Sprint(want) {
+			t.Errorf("%s 期望 %v，实际 %v", sheet, want, rows)
+		}
+	}
+}
+
+func TestRenderMultipleBlocks(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"对账": {
+				{"", "对账单"},
+				{"表头", "单号", "金额"},
+				{"数据", "", ""},
+				{"数据", "", ""},
+				{"列表", "matched"},
+				{"数据字段", "单号", "金额"},
+				{"", "小计"},
+				{"表头", "单号", "原因"},
+				{"数据", "", ""},
+				{"列表", "unmatched"},
+				{"数据字段", "单号", "原因"},
+				{"", "结束"},
+			},
+		})
+		et.File.SetCellFormula("对账", "C7", "SUM(C3:C4)")
+		et.Streaming = streaming
+		f, err := et.Render(map[string]any{
+			"matched":   []map[string]any{{"单号": "M1", "金额": 1}, {"单号": "M2", "金额": 2}, {"单号": "M3", "金额": 3}},
+			"unmatched": []map[string]any{{"单号": "U1", "原因": "缺失"}, {"单号": "U2", "原因": "金额不符"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _ := f.GetRows("对账")
+		want := [][]string{{"对账单"}, {"单号", "金额"}, {"M1", "1"}, {"M2", "2"}, {"M3", "3"}, {"小计", ""}, {"单号", "原因"}, {"U1", "缺失"}, {"U2", "金额不符"}, {"结束"}}
+		if fmt.Sprint(rows) != fmt.Sprint(want) {
+			t.Errorf("streaming=%v 期望 %v，实际 %v", streaming, want, rows)
+		}
+		formula, _ := f.GetCellFormula("对账", "B6")
+		if formula != "SUM(B3:B5)" {
+			t.Errorf("streaming=%v 小计公式不正确: %s", streaming, formula)
+		}
+	}
+}
+
+func TestRenderSection(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"对账": {
+				{"", "对账单"},
+				{"分组", "customers"},
+				{"", "客户：{{.客户名称}}"},
+				{"", ""},
+				{"表头", "订单号", "金额"},
+				{"数据", "", ""},
+				{"列表", "orders"},
+				{"数据字段", "订单号", "金额"},
+				{"分组结束"},
+				{"", "{{.生成日期}}"},
+			},
+		})
+		et.File.MergeCell("对账", "B3", "B4")
+		// 分组区域中的模板行不支持流式写出，自动使用普通模式渲染
+		et.Streaming = streaming
+		f, err := et.Render(map[string]any{
+			"生成日期": "2025-04-28",
+			"customers": []map[string]any{
+				{"客户名称": "A", "orders": []map[string]any{{"订单号": "O1", "金额": 1}, {"订单号": "O2", "金额": 2}}},
+				{"客户名称": "B", "orders": []map[string]any{{"订单号": "O3", "金额": 3}}},
+				{"客户名称": "C"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _ := f.GetRows("对账")
+		want := [][]string{
+			{"对账单"},
+			{"客户：A"}, {}, {"订单号", "金额"}, {"O1", "1"}, {"O2", "2"},
+			{"客户：B"}, {}, {"订单号", "金额"}, {"O3", "3"},
+			{"客户：C"}, {}, {"订单号", "金额"}, {},
+			{"2025-04-28"},
+		}
+		if fmt.Sprint(rows) != fmt.Sprint(want) {
+			t.Errorf("streaming=%v 期望 %v，实际 %v", streaming, want, rows)
+		}
+		mergeCells, _ := f.GetMergeCells("对账")
+		refs := lo.Map(mergeCells, func(mergeCell excelize.MergeCell, _ int) string {
+			return mergeCell.GetStartAxis() + ":" + mergeCell.GetEndAxis()
+		})
+		sort.Strings(refs)
+		if fmt.Sprint(refs) != "[A11:A12 A2:A3 A7:A8]" {
+			t.Errorf("streaming=%v 合并单元格不正确: %v", streaming, refs)
+		}
+	}
+}
+
+func TestRenderCloneSheet(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"A汇总": {
+			{"", "客户数：{{len .customers}}"},
+		},
+		"B客户": {
+			{"复制工作表", "customers", "{{.客户名称}}"},
+			{"", "客户：{{.客户名称}}", "{{.生成日期}}"},
+			{"表头", "订单号", "金额"},
+			{"数据", "", ""},
+			{"列表", "orders"},
+			{"数据字段", "订单号", "金额"},
+		},
+		"C备注": {
+			{"", "备注"},
+		},
+	})
+	f, err := et.Render(map[string]any{
+		"生成日期": "2025-04-28",
+		"customers": []map[string]any{
+			{"客户名称": "恒张三", "orders": []map[string]any{{"订单号": "O1", "金额": 1}, {"订单号": "O2", "金额": 2}}},
+			{"客户名称": "a/b:c", "orders": []map[string]any{{"订单号": "O3", "金额": 3}}},
+			{"客户名称": "恒张三"},
+			{"客户名称": strings.Repeat("长", 40)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	longName := strings.Repeat("长", 31)
+	wantSheets := []string{"A汇总", "恒张三", "a_b_c", "恒张三 (2)", longName, "C备注"}
+	if fmt.Sprint(f.GetSheetList()) != fmt.Sprint(wantSheets) {
+		t.Fatalf("工作表期望 %v，实际 %v", wantSheets, f.GetSheetList())
+	}
+	expected := map[string][][]string{
+		"A汇总":     {{"", "客户数：4"}},
+		"恒张三":     {{"客户：恒张三", "2025-04-28"}, {"订单号", "金额"}, {"O1", "1"}, {"O2", "2"}},
+		"a_b_c":   {{"客户：a/b:c", "2025-04-28"}, {"订单号", "金额"}, {"O3", "3"}},
+		"恒张三 (2)": {{"客户：恒张三", "2025-04-28"}, {"订单号", "金额"}},
+	}
+	for sheet, want := range expected {
+		rows, _ := f.GetRows(sheet)
+		if fmt.Sprint(rows) != fmt.Sprint(want) {
+			t.Errorf("%s 期望 %v，实际 %v", sheet, want, rows)
+		}
+	}
+}
+
+func TestRenderCloneSheetEmptyList(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"客户": {
+			{"复制工作表", "customers", "{{.客户名称}}"},
+			{"", "生成日期：{{.生成日期}}"},
+			{"表头", "订单号", "金额"},
+			{"数据", "", ""},
+			{"列表", "orders"},
+			{"数据字段", "订单号", "金额"},
+		},
+	})
+	f, err := et.Render(map[string]any{"生成日期": "2025-04-28", "customers": []map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 唯一的工作表不能删除，保留的模板工作表使用整体数据渲染
+	if fmt.Sprint(f.GetSheetList()) != "[客户]" {
+		t.Fatalf("工作表期望 [客户]，实际 %v", f.GetSheetList())
+	}
+	rows, _ := f.GetRows("客户")
+	if want := "[[生成日期：2025-04-28] [订单号 金额]]"; fmt.Sprint(rows) != want {
+		t.Errorf("期望 %s，实际 %v", want, rows)
+	}
+}
+
+func TestRenderHorizontalList(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"报表": {
+				{"", "销售报表"},
+				{"横向列表", "", "months"},
+				{"表头", "产品", "{{.月份}}", "合计"},
+				{"数据", "", "", ""},
+				{"数据", "", "", ""},
+				{"数据字段", "产品", "{{index .销量 .月份}}", ""},
+				{"", "总计", "", ""},
+			},
+		})
+		et.File.MergeCell("报表", "B1", "D1")
+		et.File.SetCellFormula("报表", "D4", "SUM(C4:C4)")
+		et.File.SetCellFormula("报表", "D5", "SUM(C5:C5)")
+		et.File.SetCellFormula("报表", "C7", "SUM(C4:C5)")
+		et.Streaming = streaming
+		sales := func(values ...int) map[string]any {
+			return map[string]any{"1月": values[0], "2月": values[1], "3月": values[2]}
+		}
+		f, err := et.Render(map[string]any{
+			"months": []map[string]any{{"月份": "1月"}, {"月份": "2月"}, {"月份": "3月"}},
+			"table": []map[string]any{
+				{"产品": "A", "销量": sales(1, 2, 3)},
+				{"产品": "B", "销量": sales(4, 5, 6)},
+				{"产品": "C", "销量": sales(7, 8, 9)},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _ := f.GetRows("报表")
+		want := [][]string{{"销售报表"}, {"产品", "1月", "2月", "3月", "合计"}, {"A", "1", "2", "3", ""}, {"B", "4", "5", "6", ""}, {"C", "7", "8", "9", ""}, {"总计", "", "", ""}}
+		if fmt.Sprint(rows) != fmt.Sprint(want) {
+			t.Errorf("streaming=%v 期望 %v，实际 %v", streaming, want, rows)
+		}
+		formulas := map[string]string{"E3": "SUM(B3:D3)", "E5": "SUM(B5:D5)", "B6": "SUM(B3:B5)", "D6": "SUM(D3:D5)"}
+		for cell, want := range formulas {
+			if formula, _ := f.GetCellFormula("报表", cell); formula != want {
+				t.Errorf("streaming=%v %s 公式期望 %s，实际 %s", streaming, cell, want, formula)
+			}
+		}
+		mergeCells, _ := f.GetMergeCells("报表")
+		if len(mergeCells) != 1 || mergeCells[0].GetCellValue() != "销售报表" || mergeCells[0].GetEndAxis() != "E1" {
+			t.Errorf("streaming=%v 标题合并单元格不正确: %v", streaming, mergeCells)
+		}
+	}
+}
+
+func TestRenderScalarList(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"报表": {
+			{"横向列表", "", "months"},
+			{"表头", "产品", "{{.value}}"},
+			{"数据", "", ""},
+			{"数据字段", "产品", "{{index .销量 .value}}"},
+		},
+	})
+	// 横向列表的元素可以是文字，列表中的 null 和不是对象的元素跳过
+	f, err := et.Render(map[string]any{
+		"months": []any{"1月", nil, "2月"},
+		"table":  []any{map[string]any{"产品": "A", "销量": map[string]any{"1月": 1, "2月": 2}}, nil, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := f.GetRows("报表")
+	want := "[[产品 1月 2月] [A 1 2]]"
+	if fmt.Sprint(rows) != want {
+		t.Errorf("期望 %s，实际 %v", want, rows)
+	}
+}
+
+func TestRenderDynamicColumns(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"导出": {
+			{"表头", "订单号", "动态", "金额"},
+			{"数据", "", "", ""},
+			{"动态列", "", "columns", ""},
+			{"数据字段", "订单号", "", "金额"},
+		},
+		"汇总": {
+			{"表头", "客户名称", "动态", "金额"},
+			{"数据", "", "", ""},
+			{"动态列", "", "columns", ""},
+			{"数据字段", "客户名称", "", "金额"},
+			{"分类汇总", "分类", "", "求和"},
+		},
+	})
+	numFmt := 4
+	amountStyle, err := et.File.NewStyle(&excelize.Style{NumFmt: numFmt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	et.File.SetCellStyle("导出", "D2", "D2", amountStyle)
+	f, err := et.Render(map[string]any{
+		"columns": []DynamicColumn{
+			{Header: "客户", Field: "客户名称", Width: 20},
+			{Header: "数量", Field: "数量", StyleColumn: "金额"},
+		},
+		"table": []map[string]any{
+			{"订单号": "O1", "客户名称": "张三", "数量": 1, "金额": 100},
+			{"订单号": "O2", "客户名称": "李四", "数量": 2, "金额": 200},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := f.GetRows("导出")
+	want := [][]string{{"订单号", "客户", "数量", "金额"}, {"O1", "张三", "1.00", "100.00"}, {"O2", "李四", "2.00", "200.00"}}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("期望 %v，实际 %v", want, rows)
+	}
+	if width, _ := f.GetColWidth("导出", "B"); width != 20 {
+		t.Errorf("动态列宽度期望 20，实际 %v", width)
+	}
+	if styleId, _ := f.GetCellStyle("导出", "C3"); styleId != amountStyle {
+		t.Errorf("动态列样式期望 %d，实际 %d", amountStyle, styleId)
+	}
+	filter := lo.Filter(f.GetDefinedName(), func(name excelize.DefinedName, _ int) bool {
+		return name.Name == "_xlnm._FilterDatabase" && name.Scope == "导出"
+	})
+	if len(filter) != 1 || filter[0].RefersTo != "导出!$A$1:$D$3" {
+		t.Errorf("自动筛选范围不正确: %v", filter)
+	}
+	// 分类汇总列随动态列右移
+	if formula, _ := f.GetCellFormula("汇总", "D3"); formula != "SUBTOTAL(9,D2:D2)" {
+		t.Errorf("分类汇总公式不正确: %s", formula)
+	}
+}
+
+func TestRenderLanguage(t *testing.T) {
+	sheets := map[string][][]any{
+		"中文": {
+			{"表头", "单号", "金额"},
+			{"数据", "", ""},
+			{"数据字段", "单号", "金额"},
+			{"Data", "备注"},
+		},
+		"English": {
+			{"Header", "No", "Amount"},
+			{"Data", "", ""},
+			{"DataField", "单号", "金额"},
+		},
+	}
+	fillData := map[string]any{"table": []map[string]any{{"单号": "A1", "金额": 1}, {"单号": "A2", "金额": 2}}}
+
+	// 自动识别：同一模板中的中文和英文工作表分别按各自的语言解析
+	et := newTestTemplate(t, sheets)
+	f, err := et.Render(fillData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ := f.GetRows("中文")
+	want := [][]string{{"单号", "金额"}, {"A1", "1"}, {"A2", "2"}, {"备注"}}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("中文工作表期望 %v，实际 %v", want, rows)
+	}
+	rows, _ = f.GetRows("English")
+	want = [][]string{{"No", "Amount"}, {"A1", "1"}, {"A2", "2"}}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("英文工作表期望 %v，实际 %v", want, rows)
+	}
+
+	// 指定语言：其他语言的配置行作为普通内容保留
+	et = newTestTemplate(t, sheets)
+	et.Language = constant.Chinese
+	f, err = et.Render(fillData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, _ = f.GetRows("English")
+	if len(rows) != 3 || rows[0][0] != "Header" {
+		t.Errorf("指定中文时英文配置行不应被解析: %v", rows)
+	}
+
+	et = newTestTemplate(t, sheets)
+	et.Language = "fr"
+	if _, err = et.Render(fillData); err == nil {
+		t.Error("未知语言应返回错误")
+	}
+}
+
+func TestRenderSubtotalLanguage(t *testing.T) {
+	keywords, _ := constant.GetKeywords(constant.English)
+	custom := maps.Clone(keywords)
+	custom[constant.KeyHeader] = "Kopf"
+	custom[constant.KeySubtotal] = "Zwischensumme"
+	custom[constant.KeyGroup] = "Gruppe"
+	custom[constant.KeySum] = "Summe"
+	custom[constant.KeySum+constant.KeySuffix] = "Summe"
+	custom[constant.KeySum+constant.KeyTotal] = "Gesamtsumme"
+	if err := constant.RegisterLanguage("de", custom); err != nil {
+		t.Fatal(err)
+	}
+	delete(custom, constant.KeyList)
+	if err := constant.RegisterLanguage("incomplete", custom); err == nil {
+		t.Error("缺少关键字的语言包应返回错误")
+	}
+
+	et := newTestTemplate(t, map[string][][]any{
+		"English": {
+			{"Header", "Customer", "Amount"},
+			{"Data", "", ""},
+			{"DataField", "客户", "金额"},
+			{"Subtotal", "Group", "Sum"},
+		},
+		"Deutsch": {
+			{"Kopf", "Kunde", "Betrag"},
+			{"Data", "", ""},
+			{"DataField", "客户", "金额"},
+			{"Zwischensumme", "Gruppe", "Summe"},
+		},
+	})
+	f, err := et.Render(map[string]any{"table": []map[string]any{{"客户": "A", "金额": 1}, {"客户": "A", "金额": 2}, {"客户": "B", "金额": 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sheet, want := range map[string][]string{
+		"English": {"A Total", "B Total", "Grand Total"},
+		"Deutsch": {"A Summe", "B Summe", "Gesamtsumme"},
+	} {
+		labels := lo.Map([]string{"A4", "A6", "A7"}, func(cellName string, _ int) string {
+			value, _ := f.GetCellValue(sheet, cellName)
+			return value
+		})
+		if fmt.Sprint(labels) != fmt.Sprint(want) {
+			t.Errorf("%s 分类汇总文字期望 %v，实际 %v", sheet, want, labels)
+		}
+	}
+	if formula, _ := f.GetCellFormula("English", "B4"); formula != "SUBTOTAL(9,B2:B3)" {
+		t.Errorf("分类汇总公式不正确: %s", formula)
+	}
+}
+
+func TestSubtotals(t *testing.T) {
+	// 导出的 Subtotals 与默认语言渲染时使用的汇总函数一致
+	keywords, _ := constant.GetKeywords(constant.DefaultLanguage)
+	for i, name := range constant.SubtotalFuncs {
+		subtotal, ok := subtotalOf(keywords, name)
+		if !ok || subtotal != Subtotals[i] {
+			t.Errorf("%s 期望 %+v，实际 %+v", name, Subtotals[i], subtotal)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	sheets := map[string][][]any{
+		"订单": {
+			{"", "{{index .标签 1}}"},
+			{"表头", "单号", "标签"},
+			{"数据", "", ""},
+			{"数据字段", "单号", "{{index .标签 1}}"},
+		},
+	}
+	fillData := map[string]any{
+		"标签": []string{"a"},
+		"table": []map[string]any{
+			{"单号": "A1", "标签": []string{"x"}},
+			{"单号": "A2", "标签": []string{"x", "y"}},
+			{"单号": "A3"},
+		},
+	}
+	for _, streaming := range []bool{false, true} {
+		// 默认遇到第一个错误时停止渲染
+		et := newTestTemplate(t, sheets)
+		et.Streaming = streaming
+		_, err := et.Render(map[string]any{"标签": []string{"a", "b"}, "table": fillData["table"]})
+		var renderErr *RenderError
+		if !errors.As(err, &renderErr) {
+			t.Fatalf("streaming=%v 应返回 RenderError: %v", streaming, err)
+		}
+		if renderErr.Sheet != "订单" || renderErr.Cell != "B3" || renderErr.Row != 0 || renderErr.Phase != PhaseTemplate || renderErr.Field != "{{index .标签 1}}" {
+			t.Errorf("streaming=%v 错误位置不正确: %+v", streaming, renderErr)
+		}
+
+		// 收集模式返回渲染结果和所有错误
+		et = newTestTemplate(t, sheets)
+		et.Streaming = streaming
+		et.CollectErrors = true
+		f, err := et.Render(fillData)
+		var renderErrs RenderErrors
+		if f == nil || !errors.As(err, &renderErrs) {
+			t.Fatalf("streaming=%v 收集模式应同时返回文件和 RenderErrors: %v", streaming, err)
+		}
+		cells := lo.Map(renderErrs, func(e *RenderError, _ int) string { return fmt.Sprintf("%s:%d", e.Cell, e.Row) })
+		if fmt.Sprint(cells) != "[A1:-1 B3:0 B5:2]" {
+			t.Errorf("streaming=%v 收集的错误不正确: %v", streaming, err)
+		}
+		// 流式写出的内容需要保存后重新读取
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, _ := f.GetCellValue("订单", "B4"); value != "y" {
+			t.Errorf("streaming=%v 出错后应继续渲染后续数据行，B4: %s", streaming, value)
+		}
+	}
+}
+
+func TestRenderMissingFields(t *testing.T) {
+	sheets := map[string][][]any{
+		"订单": {
+			{"表头", "单号", "数量", "备注", "描述"},
+			{"数据", "", "", "", ""},
+			{"数据字段", "单号", "数量", "备注", "{{.单号}}-{{.备注}}"},
+			{"字体色", `=IF(数量>1,"FF0000","")`},
+			{"默认值", "", 0, "无"},
+		},
+	}
+	fillData := map[string]any{"table": []map[string]any{{"单号": "A1", "数量": 2, "备注": "x"}, {"单号": "A2"}}}
+	for policy, want := range map[MissingFieldPolicy]string{
+		MissingFieldBlank:   "[[单号 数量 备注 描述] [A1 2 x A1-x] [A2   A2-]]",
+		MissingFieldDefault: "[[单号 数量 备注 描述] [A1 2 x A1-x] [A2 0 无 A2-无]]",
+	} {
+		et := newTestTemplate(t, sheets)
+		et.MissingFields = policy
+		f, err := et.Render(fillData)
+		if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		rows, _ := f.GetRows("订单")
+		if fmt.Sprint(rows) != want {
+			t.Errorf("%s 期望 %s，实际 %v", policy, want, rows)
+		}
+		// 默认值中的数字按数字写入
+		if cellType, _ := f.GetCellType("订单", "B3"); policy == MissingFieldDefault && cellType != excelize.CellTypeUnset {
+			t.Errorf("默认值应按数字写入，实际类型 %v", cellType)
+		}
+	}
+
+	et := newTestTemplate(t, sheets)
+	et.MissingFields = MissingFieldError
+	et.CollectErrors = true
+	_, err := et.Render(fillData)
+	if !errors.Is(err, ErrMissingField) {
+		t.Fatalf("缺少字段应返回 ErrMissingField: %v", err)
+	}
+	var renderErrs RenderErrors
+	errors.As(err, &renderErrs)
+	phases := lo.Map(renderErrs, func(e *RenderError, _ int) string { return e.Cell + ":" + e.Phase })
+	if fmt.Sprint(phases) != "[A3:style B3:data C3:data D3:template]" {
+		t.Errorf("缺少字段的错误不正确: %v", err)
+	}
+}
+
+func TestRenderMissingFieldDefaults(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "单号", "描述", "说明"},
+				{"数据", "", "", ""},
+				{"数据字段", "单号", "{{.描述}}", "{{.单号}}:{{.描述}}"},
+				{"背景色", `=IF(描述="暂无","FF0000","")`, "", ""},
+				{"默认值", "", "暂无", ""},
+			},
+		})
+		et.Streaming = streaming
+		et.MissingFields = MissingFieldDefault
+		f, err := et.Render(map[string]any{"table": []map[string]any{{"单号": "A1", "描述": "有"}, {"单号": "A2"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 模板字段列中的默认值作用于模板和颜色表达式引用的字段
+		rows, _ := f.GetRows("订单")
+		if want := "[[单号 描述 说明] [A1 有 A1:有] [A2 暂无 A2:暂无]]"; fmt.Sprint(rows) != want {
+			t.Errorf("streaming=%v 期望 %s，实际 %v", streaming, want, rows)
+		}
+		for cell, want := range map[string]string{"A2": "", "A3": "FF0000"} {
+			styleID, _ := f.GetCellStyle("订单", cell)
+			style, _ := f.GetStyle(styleID)
+			color := ""
+			if style != nil && len(style.Fill.Color) > 0 {
+				color = style.Fill.Color[0]
+			}
+			if color != want {
+				t.Errorf("streaming=%v %s 背景色期望 %q，实际 %q", streaming, cell, want, color)
+			}
+		}
+	}
+}
+
+func TestRenderTemplateTypes(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"汇总": {
+			{"", "{{.总金额}}", "{{.编号}}", "={{.总金额}}*2", "{{.总金额}}", "{{printf \"%.1f\" .总金额}}", "{{.日期}}", "合计:{{.总金额}}"},
+			{"表头", "数量"},
+			{"数据", ""},
+			{"数据字段", "{{.数量}}"},
+		},
+	})
+	textStyle, _ := et.File.NewStyle(&excelize.Style{NumFmt: 49})
+	numberStyle, _ := et.File.NewStyle(&excelize.Style{NumFmt: 2})
+	dateFormat := "yyyy年m月d日"
+	dateStyle, _ := et.File.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	et.File.SetCellStyle("汇总", "E1", "E1", textStyle)
+	et.File.SetCellStyle("汇总", "F1", "F1", numberStyle)
+	et.File.SetCellStyle("汇总", "G1", "G1", dateStyle)
+	f, err := et.Render(map[string]any{
+		"总金额":   12.5,
+		"编号":    "00123",
+		"日期":    "2025-04-28",
+		"table": []map[string]any{{"数量": 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cell, want := range map[string]string{
+		"A1": "12.5",
+		"B1": "00123",
+		"D1": "12.5",
+		"E1": "12.50",
+		"F1": "2025年4月28日",
+		"G1": "合计:12.5",
+		"A3": "3",
+	} {
+		if got, _ := f.GetCellValue("汇总", cell); got != want {
+			t.Errorf("%s 期望 %q，实际 %q", cell, want, got)
+		}
+	}
+	for cell, want := range map[string]excelize.CellType{
+		"A1": excelize.CellTypeUnset,
+		"B1": excelize.CellTypeSharedString,
+		"D1": excelize.CellTypeSharedString,
+		"E1": excelize.CellTypeUnset,
+		"F1": excelize.CellTypeUnset,
+		"A3": excelize.CellTypeUnset,
+	} {
+		if got, _ := f.GetCellType("汇总", cell); got != want {
+			t.Errorf("%s 类型期望 %v，实际 %v", cell, want, got)
+		}
+	}
+	if formula, _ := f.GetCellFormula("汇总", "C1"); formula != "12.5*2" {
+		t.Errorf("= 开头的模板应写入公式，实际 %q", formula)
+	}
+}
+
+func TestRenderDates(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "下单时间", "签收时间", "备注"},
+				{"数据", "", "", ""},
+				{"数据字段", "下单时间", "签收时间", "备注"},
+				{"日期解析格式", "", "2006-01-02 15:04:05"},
+			},
+		})
+		dateFormat := "yyyy-mm-dd hh:mm"
+		dateStyle, _ := et.File.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+		et.File.SetCellStyle("订单", "B2", "B2", dateStyle)
+		et.TimeZone = time.FixedZone("CST", 8*3600)
+		et.Streaming = streaming
+		f, err := et.Render(map[string]any{"table": []map[string]any{
+			{"下单时间": time.Date(2025, 4, 28, 9, 28, 55, 0, time.UTC), "签收时间": "2025-04-29 10:00:00", "备注": "2025-04-28"},
+			{"下单时间": time.Time{}, "签收时间": "", "备注": ""},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 时间转换到 TimeZone，保留模板中的日期格式；没有日期格式的列使用内置日期时间格式；未配置解析格式的文本不转换
+		rows, _ := f.GetRows("订单")
+		want := "[[下单时间 签收时间 备注] [2025-04-28 17:28 4/29/25 10:00 2025-04-28]]"
+		if fmt.Sprint(rows) != want {
+			t.Errorf("streaming=%v 期望 %s，实际 %v", streaming, want, rows)
+		}
+		if cellType, _ := f.GetCellType("订单", "B2"); cellType != excelize.CellTypeUnset {
+			t.Errorf("streaming=%v 解析后的日期应按数字写入，实际类型 %v", streaming, cellType)
+		}
+	}
+
+	et := newTestTemplate(t, map[string][][]any{
+		"订单": {
+			{"表头", "签收时间"},
+			{"数据", ""},
+			{"数据字段", "签收时间"},
+			{"日期解析格式", "2006-01-02"},
+		},
+	})
+	_, err := et.Render(map[string]any{"table": []map[string]any{{"签收时间": "2025/04/29"}}})
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) || renderErr.Phase != PhaseData || renderErr.Cell != "A2" {
+		t.Errorf("日期解析失败应返回 RenderError: %v", err)
+	}
+}
+
+func TestRenderColumnFormats(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "客户", "金额", "备注"},
+				{"数据", "", "", ""},
+				{"数据字段", "客户", "金额", "备注"},
+				{"数字格式", "", `=IF(币种="USD","$#,##0.00","#,##0")`, "@"},
+				{"列宽", "auto", "", "30"},
+			},
+		})
+		et.Streaming = streaming
+		f, err := et.Render(map[string]any{"table": []map[string]any{
+			{"客户": "上海某某科技有限公司", "金额": 1234.5, "币种": "USD", "备注": "a"},
+			{"客户": "ACME", "金额": 1234.5, "币种": "CNY", "备注": "b"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 数字格式按行计算，同一列可以使用不同的格式
+		rows, _ := f.GetRows("订单")
+		want := "[[客户 金额 备注] [上海某某科技有限公司 $1,234.50 a] [ACME 1,235 b]]"
+		if fmt.Sprint(rows) != want {
+			t.Errorf("streaming=%v 期望 %s，实际 %v", streaming, want, rows)
+		}
+		// auto 列宽按最长的文字计算，中文按 2 个字符
+		if width, _ := f.GetColWidth("订单", "A"); width != 22 {
+			t.Errorf("streaming=%v A 列宽期望 22，实际 %v", streaming, width)
+		}
+		if width, _ := f.GetColWidth("订单", "C"); width != 30 {
+			t.Errorf("streaming=%v C 列宽期望 30，实际 %v", streaming, width)
+		}
+	}
+}
+
+func TestRenderAutoFit(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "客户", "备注", "金额"},
+				{"数据", "", "", ""},
+				{"数据字段", "客户", "备注", "金额"},
+			},
+		})
+		wrapStyle, _ := et.File.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true}})
+		et.File.SetCellStyle("订单", "C2", "C2", wrapStyle)
+		largeStyle, _ := et.File.NewStyle(&excelize.Style{Font: &excelize.Font{Size: 22}})
+		et.File.SetCellStyle("订单", "D2", "D2", largeStyle)
+		et.Streaming = streaming
+		et.AutoFit = &AutoFitOptions{MaxColumnWidth: 20, MaxRowHeight: 100}
+		f, err := et.Render(map[string]any{"table": []map[string]any{
+			{"客户": "上海某某科技有限公司", "备注": strings.Repeat("很长的备注", 6), "金额": "12345678"},
+			{"客户": "ACME", "备注": "好", "金额": "1"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 列宽不超过上限，字号 22 的列按 2 倍宽度计算
+		for col, want := range map[string]float64{"A": 20, "B": 20, "C": 18} {
+			if width, _ := f.GetColWidth("订单", col); width != want {
+				t.Errorf("streaming=%v %s 列宽期望 %v，实际 %v", streaming, col, want, width)
+			}
+		}
+		// 60 个字符宽的备注在 20 宽的列中换为 3 行，没有换行的数据行保持模板行高
+		for row, want := range map[int]float64{2: 45, 3: 15} {
+			if height, _ := f.GetRowHeight("订单", row); height != want {
+				t.Errorf("streaming=%v 第 %d 行行高期望 %v，实际 %v", streaming, row, want, height)
+			}
+		}
+	}
+}
+
+func TestRenderAutoFitConfiguredWidth(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "备注"},
+				{"数据", ""},
+				{"数据字段", "备注"},
+				{"列宽", "10"},
+			},
+		})
+		wrapStyle, _ := et.File.NewStyle(&excelize.Style{Alignment: &excelize.Alignment{WrapText: true}})
+		et.File.SetCellStyle("订单", "B2", "B2", wrapStyle)
+		// 模板中的列宽与配置的列宽不同，行高按配置的列宽计算
+		et.File.SetColWidth("订单", "B", "B", 50)
+		et.Streaming = streaming
+		et.AutoFit = &AutoFitOptions{}
+		f, err := et.Render(map[string]any{"table": []map[string]any{{"备注": strings.Repeat("很长的备注", 6)}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if width, _ := f.GetColWidth("订单", "A"); width != 10 {
+			t.Errorf("streaming=%v 列宽期望 10，实际 %v", streaming, width)
+		}
+		if height, _ := f.GetRowHeight("订单", 2); height != 90 {
+			t.Errorf("streaming=%v 行高期望 90，实际 %v", streaming, height)
+		}
+	}
+}
+
+func TestRenderConditionalColors(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		et := newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "单号", "状态", "金额"},
+				{"数据", "", "", ""},
+				{"数据字段", "单号", "状态", "金额"},
+				{"背景色", `=IF(状态="完成","ffff00","")`, "", `=IF(备注="急","ff0000","")`},
+				{"字体色", "", `=IF(金额>100,"FF0000","0000FF")`, ""},
+			},
+		})
+		et.Streaming = streaming
+		et.ConditionalColors = true
+		f, err := et.Render(map[string]any{"table": []map[string]any{
+			{"单号": "A001", "状态": "完成", "金额": 200, "备注": "急"},
+			{"单号": "A002", "状态": "待发货", "金额": 50, "备注": ""},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf, err := f.WriteToBuffer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err = excelize.OpenReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 字段替换为同一行所在列的单元格，每个颜色常量一条规则
+		formats, err := f.GetConditionalFormats("订单")
+		if err != nil {
+			t.Fatal(err)
+		}
+		criteria := make(map[string][]string)
+		for ref, options := range formats {
+			for _, option := range options {
+				criteria[ref] = append(criteria[ref], option.Criteria)
+			}
+		}
+		want := map[string][]string{
+			"A2:A3": {`(IF($B2="完成","ffff00",""))="ffff00"`},
+			"B2:B3": {`(IF($C2>100,"FF0000","0000FF"))="FF0000"`, `(IF($C2>100,"FF0000","0000FF"))="0000FF"`},
+		}
+		if fmt.Sprint(criteria) != fmt.Sprint(want) {
+			t.Errorf("streaming=%v 期望条件格式 %v，实际 %v", streaming, want, criteria)
+		}
+		// 转换后的列不再写入单元格颜色，引用了不在列表中的字段的表达式仍逐个单元格计算
+		styleId, _ := f.GetCellStyle("订单", "A2")
+		if style, _ := f.GetStyle(styleId); len(style.Fill.Color) > 0 {
+			t.Errorf("streaming=%v A2 不应设置背景色: %+v", streaming, style.Fill)
+		}
+		styleId, _ = f.GetCellStyle("订单", "C2")
+		if style, _ := f.GetStyle(styleId); fmt.Sprint(style.Fill.Color) != "[FF0000]" {
+			t.Errorf("streaming=%v C2 背景色期望 FF0000，实际 %+v", streaming, style.Fill)
+		}
+
+		// 分类汇总行不在条件格式的范围内
+		et = newTestTemplate(t, map[string][][]any{
+			"订单": {
+				{"表头", "单号", "状态", "金额"},
+				{"数据", "", "", ""},
+				{"数据字段", "单号", "状态", "金额"},
+				{"背景色", `=IF(金额>100,"ffff00","")`, "", ""},
+				{"分类汇总", "", "分类", "求和"},
+			},
+		})
+		et.Streaming = streaming
+		et.ConditionalColors = true
+		f, err = et.Render(map[string]any{"table": []map[string]any{
+			{"单号": "A001", "状态": "完成", "金额": 200},
+			{"单号": "A002", "状态": "完成", "金额": 50},
+			{"单号": "A003", "状态": "待发货", "金额": 300},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		formats, err = f.GetConditionalFormats("订单")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := formats["A2:A3 A5:A5"]; !ok || len(formats) != 1 {
+			t.Errorf("streaming=%v 条件格式范围期望 A2:A3 A5:A5，实际 %v", streaming, lo.Keys(formats))
+		}
+	}
+}
+
+func TestRenderConditionalColorsBlocks(t *testing.T) {
+	// 下方的区域先渲染，上方区域插入行后条件格式的公式仍引用同一行
+	et := newTestTemplate(t, map[string][][]any{
+		"订单": {
+			{"表头", "单号", "金额"},
+			{"数据", "", ""},
+			{"列表", "a"},
+			{"数据字段", "单号", "金额"},
+			{"背景色", "", `=IF(金额>10,"FF0000","")`},
+			{"表头", "单号", "金额"},
+			{"数据", "", ""},
+			{"列表", "b"},
+			{"数据字段", "单号", "金额"},
+			{"背景色", "", `=IF(金额>10,"FF0000","")`},
+		},
+	})
+	et.ConditionalColors = true
+	rows := []map[string]any{{"单号": "A1", "金额": 5}, {"单号": "A2", "金额": 20}, {"单号": "A3", "金额": 30}}
+	f, err := et.Render(map[string]any{"a": rows, "b": rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	formats, err := f.GetConditionalFormats("订单")
+	if err != nil {
+		t.Fatal(err)
+	}
+	criteria := make(map[string]string)
+	for ref, options := range formats {
+		criteria[ref] = options[0].Criteria
+	}
+	want := map[string]string{
+		"B2:B4": `(IF($B2>10,"FF0000",""))="FF0000"`,
+		"B6:B8": `(IF($B6>10,"FF0000",""))="FF0000"`,
+	}
+	if fmt.Sprint(criteria) != fmt.Sprint(want) {
+		t.Errorf("期望条件格式 %v，实际 %v", want, criteria)
+	}
+}
+
+func TestRenderImageError(t *testing.T) {
+	sheets := map[string][][]any{
+		"订单": {
+			{"表头", "单号", "图片"},
+			{"数据", "", ""},
+			{"数据字段", "单号", "图片"},
+		},
+	}
+	fillData := map[string]any{
+		"table": []map[string]any{
+			{"单号": "A1", "图片": "data:image/png;base64,!!!"},
+			{"单号": "A2", "图片": ""},
+		},
+	}
+	// 图片数据无效时在单元格中写入错误信息并继续渲染
+	et := newTestTemplate(t, sheets)
+	f, err := et.Render(fillData)
+	if err != nil {
+		t.Fatalf("图片数据无效不应停止渲染: %v", err)
+	}
+	if value, _ := f.GetCellValue("订单", "B2"); value == "" {
+		t.Error("图片数据无效时单元格中应写入错误信息")
+	}
+	if value, _ := f.GetCellValue("订单", "A3"); value != "A2" {
+		t.Errorf("应继续渲染后续数据行，A3: %s", value)
+	}
+
+	// 收集模式下记录错误
+	et = newTestTemplate(t, sheets)
+	et.CollectErrors = true
+	_, err = et.Render(fillData)
+	var renderErrs RenderErrors
+	if !errors.As(err, &renderErrs) || len(renderErrs) != 1 || renderErrs[0].Phase != PhaseImage || renderErrs[0].Cell != "B2" {
+		t.Errorf("收集模式应记录图片错误: %v", err)
+	}
+}
+
+func TestLint(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"明细": {
+			{"配置列", "明细"},
+			{"数据", "", ""},
+			{"表头", "单号", "金额"},
+			{"数据", "", ""},
+			{"数据字段", "{{.单号", "金额"},
+			{"背景色", "", `IF(金额>0,"ff0000","")`},
+			{"分类汇总", "", "求和"},
+			{"表尾", "合计", ""},
+			{"分组", "groups"},
+			{"数据", "", ""},
+			{"分组结束"},
+			{"列宽", "宽"},
+		},
+		"说明": {
+			{"任意内容", "不检查"},
+		},
+	})
+	et.File.SetCellFormula("明细", "C8", "SUM(A4:C4)")
+	et.File.SetCellFormula("明细", "A13", "A4*2")
+	et.File.SetCellFormula("明细", "B13", "COUNTA($A:$B)")
+	et.File.SetCellFormula("明细", "C13", "SUM(B:C)+SUMIF(MA:MA,1)")
+	issues := lo.Map(et.Lint(), func(issue Issue, _ int) string {
+		return issue.Cell + " " + issue.Rule
+	})
+	want := []string{
+		"B5 " + IssueInvalidTemplate,
+		"C6 " + IssueInvalidColorExpr,
+		"A7 " + IssueSubtotalWithoutGroup,
+		"A8 " + IssueUnknownKeyword,
+		"A10 " + IssueDataWithoutHeader,
+		"B12 " + IssueInvalidColumnWidth,
+		"C8 " + IssueConfigColumnReference,
+		"A13 " + IssueConfigColumnReference,
+		"B13 " + IssueConfigColumnReference,
+	}
+	if fmt.Sprint(issues) != fmt.Sprint(want) {
+		t.Errorf("期望 %v，实际 %v", want, issues)
+	}
+}
+
+func TestLintBundledTemplate(t *testing.T) {
+	et, err := OpenFile("template/template.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 示例模板 Sheet1 中的分类汇总行没有分类列
+	issues := lo.Map(et.Lint(), func(issue Issue, _ int) string {
+		return issue.String()
+	})
+	if want := `[Sheet1!A12: [subtotal-without-group] subtotal row has no "分类" column]`; fmt.Sprint(issues) != want {
+		t.Errorf("期望 %s，实际 %v", want, issues)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	et := newTestTemplate(t, map[string][][]any{
+		"汇总": {
+			{"", "{{.标题}}", "{{.签名}}", `{{range .备注}}{{.内容}}{{$.作者}}{{end}}`},
+			{"表头", "单号", "金额", "客户", "签名", "照片"},
+			{"数据", "", "", "", "", ""},
+			{"数据字段", "单号", "{{printf \"%.2f\" .金额}}", "{{.客户.名称}}", "签名", "照片"},
+			{"背景色", `=IF(状态="完成","ff0000","")`, "", ""},
+			{"动态列", "", "", "columns"},
+			{"默认值", "", "", "", "", "data:image/png;base64,iVBORw0KGgo="},
+		},
+		"明细": {
+			{"分组", "customers"},
+			{"", "{{.客户名称}}"},
+			{"表头", "订单号"},
+			{"数据", ""},
+			{"列表", "orders"},
+			{"数据字段", "订单号"},
+			{"分组结束"},
+		},
+	})
+	err := et.File.AddPicture("汇总", "C1", "./image/barcode.png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := et.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := json.Marshal(schema)
+	var got map[string]any
+	json.Unmarshal(content, &got)
+	for path, want := range map[string]string{
+		"properties.标题":                                                     "map[]",
+		"properties.签名.format":                                              "data-url",
+		"properties.table.type":                                             "array",
+		"properties.table.items.properties.单号":                              "map[]",
+		"properties.table.items.properties.金额":                              "map[]",
+		"properties.table.items.properties.客户.properties.名称":                "map[]",
+		"properties.table.items.properties.状态":                              "map[]",
+		"properties.table.items.properties.签名.format":                       "data-url",
+		"properties.table.items.properties.照片.format":                       "data-url",
+		"properties.备注":                                                     "map[]",
+		"properties.作者":                                                     "map[]",
+		"properties.columns.items.properties.Width.type":                    "number",
+		"properties.customers.items.properties.客户名称":                        "map[]",
+		"properties.customers.items.properties.orders.items.type":           "object",
+		"properties.customers.items.properties.orders.items.properties.订单号": "map[]",
+	} {
+		var value any = got
+		for _, key := range strings.Split(path, ".") {
+			m, _ := value.(map[string]any)
+			value = m[key]
+		}
+		if fmt.Sprint(value) != want {
+			t.Errorf("%s 期望 %s，实际 %v", path, want, value)
+		}
+	}
+	if _, ok := got["properties"].(map[string]any)["table"].(map[string]any)["items"].(map[string]any)["properties"].(map[string]any)["IF"]; ok {
+		t.Error("颜色表达式中的函数名不应作为字段")
+	}
+	// range 内部的 . 指向当前元素，不是填充数据的字段
+	if _, ok := got["properties"].(map[string]any)["内容"]; ok {
+		t.Error("range 内部的字段不应作为顶层字段")
+	}
+	// 模板本身不应被修改
+	if value, _ := et.File.GetCellValue("汇总", "A2"); value != "表头" {
+		t.Errorf("Schema 不应修改模板: %s", value)
+	}
+}
@@ -0,0 +1,311 @@
+package excel_template
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template/parse"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// JSONSchemaDraft Schema 生成的 JSON Schema 版本
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema 模板需要的填充数据结构，序列化后为 JSON Schema。
+// 没有 Type 的属性可以是任意值，图片字段为 Format 是 data-url 的字符串
+type JSONSchema struct {
+	Schema           string                 `json:"$schema,omitempty"`
+	Title            string                 `json:"title,omitempty"`
+	Description      string                 `json:"description,omitempty"`
+	Type             string                 `json:"type,omitempty"`
+	Format           string                 `json:"format,omitempty"`
+	ContentMediaType string                 `json:"contentMediaType,omitempty"`
+	Properties       map[string]*JSONSchema `json:"properties,omitempty"`
+	Items            *JSONSchema            `json:"items,omitempty"`
+}
+
+// property 返回对象的属性，不存在时创建
+func (s *JSONSchema) property(name string) *JSONSchema {
+	if s.Type == "" {
+		s.Type = "object"
+	}
+	if s.Properties == nil {
+		s.Properties = make(map[string]*JSONSchema)
+	}
+	if _, ok := s.Properties[name]; !ok {
+		s.Properties[name] = &JSONSchema{}
+	}
+	return s.Properties[name]
+}
+
+// field 添加字段，path 为 .a.b 形式的字段路径
+func (s *JSONSchema) field(path []string) *JSONSchema {
+	current := s
+	for _, name := range path {
+		current = current.property(name)
+	}
+	return current
+}
+
+// list 添加列表字段并返回元素的结构
+func (s *JSONSchema) list(name string) *JSONSchema {
+	list := s.property(name)
+	list.Type = "array"
+	if list.Items == nil {
+		list.Items = &JSONSchema{Type: "object"}
+	}
+	return list.Items
+}
+
+// image 将字段标记为图片
+func (s *JSONSchema) image() {
+	s.Type = "string"
+	s.Format = "data-url"
+	s.ContentMediaType = "image/*"
+}
+
+// Schema 返回模板需要的填充数据结构，包括模板单元格中的字段、列表字段、列表元素中的数据字段、
+// 数据字段模板和颜色表达式引用的字段以及图片字段。按整体数据描述，不区分 SheetData 中的工作表，模板不会被修改
+func (et *ExcelTemplate) Schema() (*JSONSchema, error) {
+	ct, err := et.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("Schema: failed to compile template [path=%s]: %w", et.TemplatePath, err)
+	}
+	return ct.Schema()
+}
+
+// Schema 返回模板需要的填充数据结构，见 ExcelTemplate.Schema
+func (ct *CompiledTemplate) Schema() (*JSONSchema, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(ct.content))
+	if err != nil {
+		return nil, fmt.Errorf("Schema: failed to open template copy [path=%s]: %w", ct.TemplatePath, err)
+	}
+	defer f.Close()
+
+	root := &JSONSchema{Schema: JSONSchemaDraft, Title: ct.TemplatePath, Type: "object"}
+	sb := &schemaBuilder{file: f, listField: ct.ListField, images: make(map[string]bool)}
+	// 按工作表顺序遍历，保证结果稳定
+	for _, sheet := range f.GetSheetList() {
+		cache, ok := ct.sheetCache[sheet]
+		if !ok {
+			continue
+		}
+		sheetSchema := root
+		if cache.Clone != nil {
+			// 复制的工作表使用元素数据渲染
+			sheetSchema = root.list(cache.Clone.ListField)
+		}
+		sb.sheet(sheet, cache, sheetSchema)
+	}
+	sb.markImages(root)
+	return root, nil
+}
+
+// schemaBuilder 遍历预解析的工作表缓存，收集字段
+type schemaBuilder struct {
+	file      *excelize.File
+	listField string
+	// 已识别为图片的字段名
+	images map[string]bool
+}
+
+// sheet 收集工作表中的字段
+func (sb *schemaBuilder) sheet(sheet string, cache *SheetCache, schema *JSONSchema) {
+	horizontalItems := make(map[*HorizontalList]*JSONSchema, len(cache.HorizontalLists))
+	for _, hl := range cache.HorizontalLists {
+		items := schema.list(hl.ListField)
+		if hl.Dynamic {
+			for _, name := range []string{"Header", "Field", "StyleColumn"} {
+				items.property(name).Type = "string"
+			}
+			items.property("Width").Type = "number"
+		}
+		horizontalItems[hl] = items
+	}
+	// 横向列表列中的模板单元格使用元素数据渲染
+	cellSchema := func(cellName string) *JSONSchema {
+		col, _, _ := excelize.CellNameToCoordinates(cellName)
+		hl, ok := lo.Find(cache.HorizontalLists, func(hl *HorizontalList) bool {
+			return !hl.Dynamic && col >= hl.StartCol && col <= hl.EndCol
+		})
+		if ok {
+			return horizontalItems[hl]
+		}
+		return schema
+	}
+
+	for _, templateCell := range cache.TemplateCells {
+		sb.templateCell(sheet, templateCell, cellSchema(templateCell.CellName))
+	}
+	for _, block := range cache.Blocks {
+		sb.block(sheet, block, schema)
+	}
+	for _, section := range cache.Sections {
+		items := schema.list(section.ListField)
+		for _, templateCell := range section.TemplateCells {
+			sb.templateCell(sheet, templateCell, items)
+		}
+		for _, block := range section.Blocks {
+			sb.block(sheet, block, items)
+		}
+	}
+}
+
+// templateCell 收集模板单元格引用的字段，只有一个字段且单元格上有图片时为图片字段
+func (sb *schemaBuilder) templateCell(sheet string, templateCell TemplateCell, schema *JSONSchema) {
+	fields := templateFields(templateCell.Template)
+	for _, path := range fields {
+		field := schema.field(path)
+		if len(fields) == 1 && sb.hasPicture(sheet, templateCell.CellName) {
+			field.image()
+			sb.images[path[len(path)-1]] = true
+		}
+	}
+}
+
+// block 收集列表区域的列表字段，以及数据字段和颜色表达式中的字段
+func (sb *schemaBuilder) block(sheet string, block *TableBlock, schema *JSONSchema) {
+	if len(block.ColumnList) == 0 || block.TemplateDataRows == 0 {
+		return
+	}
+	items := schema.list(lo.CoalesceOrEmpty(block.ListField, sb.listField))
+	for _, column := range block.ColumnList {
+		if column.DataField != "" {
+			if column.IsTemplate {
+				for _, path := range templateFields(column.DataField) {
+					items.field(path)
+				}
+			} else {
+				field := items.property(column.DataField)
+				cellName, _ := excelize.CoordinatesToCellName(column.RenderColNum, block.StartRowNum)
+				// 没有占位图片的单元格可以在默认值配置行中填写 base64 图片标记为图片字段
+				defaultValue, _ := column.DefaultValue.(string)
+				if sb.hasPicture(sheet, cellName) || IsBase64Image(defaultValue) {
+					field.image()
+					sb.images[column.DataField] = true
+				}
+			}
+		}
+		exprs := []string{column.BackgroundColorExpr, column.FontColorExpr}
+		if strings.HasPrefix(column.NumberFormat, "=") {
+			exprs = append(exprs, column.NumberFormat)
+		}
+		for _, expr := range exprs {
+			for _, name := range formulaVariables(expr) {
+				items.property(name)
+			}
+		}
+	}
+}
+
+// markImages 将与图片字段同名、没有类型的字段也标记为图片，例如只有一个单元格放置了占位图片时列表中的同名字段
+func (sb *schemaBuilder) markImages(schema *JSONSchema) {
+	for name, property := range schema.Properties {
+		if sb.images[name] && property.Type == "" {
+			property.image()
+		}
+		sb.markImages(property)
+	}
+	if schema.Items != nil {
+		sb.markImages(schema.Items)
+	}
+}
+
+// hasPicture 单元格上是否有模板中的占位图片
+func (sb *schemaBuilder) hasPicture(sheet string, cellName string) bool {
+	pictures, err := sb.file.GetPictures(sheet, cellName)
+	return err == nil && len(pictures) > 0
+}
+
+// templateFields 返回模板中引用的字段路径，range 和 with 内部的 . 不是填充数据，不在此列，
+// 其中以 $ 开头的字段（如 $.标题）引用填充数据，包含在内
+func templateFields(tmplStr string) [][]string {
+	tree := parse.New("template")
+	tree.Mode = parse.SkipFuncCheck
+	tree, err := tree.Parse(tmplStr, "", "", map[string]*parse.Tree{})
+	if err != nil || tree.Root == nil {
+		return nil
+	}
+	fields := make([][]string, 0)
+	// nested 为 true 时在 range 或 with 内部，. 指向当前元素
+	var walk func(node parse.Node, nested bool)
+	walk = func(node parse.Node, nested bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, nested)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, nested)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, nested)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, nested)
+			}
+		case *parse.FieldNode:
+			if !nested {
+				fields = append(fields, n.Ident)
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				fields = append(fields, n.Ident[1:])
+			}
+		case *parse.ChainNode:
+			walk(n.Node, nested)
+		case *parse.IfNode:
+			walk(n.Pipe, nested)
+			walk(n.List, nested)
+			walk(n.ElseList, nested)
+		case *parse.RangeNode:
+			walk(n.Pipe, nested)
+			walk(n.List, true)
+			walk(n.ElseList, nested)
+		case *parse.WithNode:
+			walk(n.Pipe, nested)
+			walk(n.List, true)
+			walk(n.ElseList, nested)
+		}
+	}
+	walk(tree.Root, false)
+	return lo.UniqBy(fields, func(path []string) string {
+		return strings.Join(path, ".")
+	})
+}
+
+// formulaStringRegexp 匹配公式中的字符串常量
+var formulaStringRegexp = regexp.MustCompile(`"[^"]*"`)
+
+// formulaVariableRegexp 匹配公式中的变量名，与 replaceVarsWithCells 一致，最后一组用于排除函数名
+var formulaVariableRegexp = regexp.MustCompile(`[\p{Han}\w]+(\s*\()?`)
+
+// formulaVariables 返回颜色表达式中引用的变量名，不包括函数名、数字和逻辑值
+func formulaVariables(expr string) []string {
+	if expr == "" {
+		return nil
+	}
+	expr = formulaStringRegexp.ReplaceAllString(expr, "")
+	names := make([]string, 0)
+	for _, match := range formulaVariableRegexp.FindAllStringSubmatch(expr, -1) {
+		name := strings.TrimRight(match[0], " (")
+		if match[1] != "" || lo.Contains([]string{"TRUE", "FALSE"}, strings.ToUpper(name)) {
+			continue
+		}
+		if name[0] >= '0' && name[0] <= '9' {
+			continue
+		}
+		names = append(names, name)
+	}
+	return lo.Uniq(names)
+}
@@ -0,0 +1,136 @@
+package excel_template
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// Section 分组区域，位于“分组”和“分组结束”配置行之间。
+// 列表中的每个元素复制一份区域内的模板行（包括合并单元格和图片），区域内的模板单元格和列表区域使用元素数据渲染，
+// 例如按客户重复客户信息和该客户的订单明细
+type Section struct {
+	// 分组使用的列表字段
+	ListField string
+	// 删除配置行后区域的起止行号
+	StartRowNum int
+	EndRowNum   int
+	// 区域内的列表区域，列表字段从元素数据中取值
+	Blocks        []*TableBlock
+	TemplateCells []TemplateCell
+}
+
+// renderSection 按列表元素复制并渲染分组区域，列表为空时删除区域内的模板行
+func (et *ExcelTemplate) renderSection(ctx context.Context, sheet string, section *Section) error {
+	fillData := et.SheetCache[sheet].FillData
+	list := getList(fillData, section.ListField)
+	height := section.EndRowNum - section.StartRowNum + 1
+	if len(list) == 0 {
+		for rowNum := section.EndRowNum; rowNum >= section.StartRowNum; rowNum-- {
+			err := et.File.RemoveRow(sheet, rowNum)
+			if err != nil {
+				return fmt.Errorf("renderSection: failed to remove row [sheet=%s, row=%d]: %w", sheet, rowNum, err)
+			}
+		}
+		return nil
+	}
+
+	err := et.copySection(sheet, section, len(list))
+	if err != nil {
+		return err
+	}
+
+	// 从下往上渲染，列表区域插入的数据行不会改变上方副本的位置
+	for i := len(list) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("renderSection: render canceled [sheet=%s, item=%d]: %w", sheet, i, err)
+		}
+		offset := i * height
+		// 元素数据优先，区域内同样可以使用整体数据
+		itemData := mergeFillData(fillData, list[i])
+
+		err = et.renderTemplateCells(sheet, section.TemplateCells, itemData, offset)
+		if err != nil {
+			return fmt.Errorf("renderSection: failed to render templates [sheet=%s, item=%d]: %w", sheet, i, err)
+		}
+		for j := len(section.Blocks) - 1; j >= 0; j-- {
+			block := *section.Blocks[j]
+			block.StartRowNum += offset
+			_, err = et.renderBlock(ctx, sheet, &block, itemData)
+			if err != nil {
+				return fmt.Errorf("renderSection: failed to process data [sheet=%s, item=%d, row=%d]: %w", sheet, i, block.StartRowNum, err)
+			}
+		}
+	}
+	return nil
+}
+
+// copySection 在分组区域下方复制 count-1 份区域内的模板行。
+// DuplicateRowTo 只复制单行的合并单元格，跨行的合并单元格和图片需要单独复制
+func (et *ExcelTemplate) copySection(sheet string, section *Section, count int) error {
+	if count <= 1 {
+		return nil
+	}
+	height := section.EndRowNum - section.StartRowNum + 1
+	inSection := func(rowNum int) bool {
+		return rowNum >= section.StartRowNum && rowNum <= section.EndRowNum
+	}
+
+	mergeCells, err := et.File.GetMergeCells(sheet)
+	if err != nil {
+		return fmt.Errorf("copySection: failed to get merge cells [sheet=%s]: %w", sheet, err)
+	}
+	mergeRanges := lo.Filter(parseMergeCells(mergeCells), func(mergeRange MergeRange, _ int) bool {
+		return mergeRange.StartRow != mergeRange.EndRow && inSection(mergeRange.StartRow) && inSection(mergeRange.EndRow)
+	})
+	pictureCells, err := et.File.GetPictureCells(sheet)
+	if err != nil {
+		return fmt.Errorf("copySection: failed to get picture cells [sheet=%s]: %w", sheet, err)
+	}
+	pictures := make(map[string][]excelize.Picture)
+	for _, cellName := range pictureCells {
+		_, row, err := excelize.CellNameToCoordinates(cellName)
+		if err != nil || !inSection(row) {
+			continue
+		}
+		pictures[cellName], err = et.File.GetPictures(sheet, cellName)
+		if err != nil {
+			return fmt.Errorf("copySection: failed to get pictures [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+		}
+	}
+
+	for i := 1; i < count; i++ {
+		offset := i * height
+		for rowNum := section.StartRowNum; rowNum <= section.EndRowNum; rowNum++ {
+			err = et.File.DuplicateRowTo(sheet, rowNum, rowNum+offset)
+			if err != nil {
+				return fmt.Errorf("copySection: failed to duplicate row [sheet=%s, row=%d]: %w", sheet, rowNum, err)
+			}
+		}
+	}
+
+	for i := 1; i < count; i++ {
+		offset := i * height
+		for _, mergeRange := range mergeRanges {
+			topLeftCell, _ := excelize.CoordinatesToCellName(mergeRange.StartCol, mergeRange.StartRow+offset)
+			bottomRightCell, _ := excelize.CoordinatesToCellName(mergeRange.EndCol, mergeRange.EndRow+offset)
+			err = et.File.MergeCell(sheet, topLeftCell, bottomRightCell)
+			if err != nil {
+				return fmt.Errorf("copySection: failed to merge [sheet=%s, cell=%s:%s]: %w", sheet, topLeftCell, bottomRightCell, err)
+			}
+		}
+		for cellName, cellPictures := range pictures {
+			col, row, _ := excelize.CellNameToCoordinates(cellName)
+			targetCell, _ := excelize.CoordinatesToCellName(col, row+offset)
+			for _, picture := range cellPictures {
+				err = et.File.AddPictureFromBytes(sheet, targetCell, &picture)
+				if err != nil {
+					return fmt.Errorf("copySection: failed to add picture [sheet=%s, cell=%s]: %w", sheet, targetCell, err)
+				}
+			}
+		}
+	}
+	return nil
+}
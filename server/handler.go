@@ -0,0 +1,237 @@
+// Package server 提供模板渲染的 HTTP 服务，模板从目录中加载
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	excel_template "github.com/mzzya/excel_template"
+)
+
+// 默认限制
+const (
+	DefaultMaxBodyBytes     = 10 << 20
+	DefaultMaxTemplateBytes = 20 << 20
+	DefaultMaxRows          = 100000
+)
+
+// xlsxContentType 渲染结果的 Content-Type
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Handler 模板渲染服务：
+//
+//	GET  /templates              模板 ID 列表
+//	PUT  /templates/{id}         上传模板，请求体为 xlsx 文件，返回检查结果，需要开启 AllowUpload
+//	GET  /templates/{id}/lint    检查模板
+//	GET  /templates/{id}/schema  模板数据结构（JSON Schema）
+//	POST /templates/{id}/render  使用 JSON 请求体渲染模板，返回 xlsx 文件
+type Handler struct {
+	Registry *Registry
+	// 渲染请求体的最大字节数
+	MaxBodyBytes int64
+	// 上传模板的最大字节数
+	MaxTemplateBytes int64
+	// 渲染数据中所有列表的元素总数上限
+	MaxRows int
+	// 是否允许通过 PUT 上传模板，上传会覆盖模板目录中的文件，默认关闭
+	AllowUpload bool
+	// ErrorLog 记录无法返回给客户端的错误，为 nil 时使用 log 包的默认 Logger
+	ErrorLog *log.Logger
+
+	mux *http.ServeMux
+}
+
+// NewHandler 使用默认限制创建 Handler
+func NewHandler(registry *Registry) *Handler {
+	h := &Handler{
+		Registry:         registry,
+		MaxBodyBytes:     DefaultMaxBodyBytes,
+		MaxTemplateBytes: DefaultMaxTemplateBytes,
+		MaxRows:          DefaultMaxRows,
+		mux:              http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /templates", h.list)
+	h.mux.HandleFunc("PUT /templates/{id}", h.upload)
+	h.mux.HandleFunc("GET /templates/{id}/lint", h.lint)
+	h.mux.HandleFunc("GET /templates/{id}/schema", h.schema)
+	h.mux.HandleFunc("POST /templates/{id}/render", h.render)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// errorResponse 错误响应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+}
+
+// writeError 按错误类型输出状态码和错误信息
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var maxBytesErr *http.MaxBytesError
+	var renderErr *excel_template.RenderError
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errUploadDisabled):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrInvalidTemplateID), errors.Is(err, ErrInvalidTemplate), errors.Is(err, errBadRequest):
+		status = http.StatusBadRequest
+	case errors.As(err, &maxBytesErr), errors.Is(err, errTooManyRows):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &renderErr):
+		// 数据与模板不匹配，如模板函数执行失败、颜色表达式计算失败
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+var (
+	errBadRequest     = errors.New("bad request")
+	errTooManyRows    = errors.New("too many rows")
+	errUploadDisabled = errors.New("template upload is disabled")
+)
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	ids, err := h.Registry.List()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"templates": ids})
+}
+
+// lintResponse 检查结果
+type lintResponse struct {
+	ID     string                 `json:"id"`
+	Issues []excel_template.Issue `json:"issues"`
+}
+
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.AllowUpload {
+		writeError(w, fmt.Errorf("upload: %w [id=%s]", errUploadDisabled, id))
+		return
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.MaxTemplateBytes))
+	if err != nil {
+		writeError(w, fmt.Errorf("upload: failed to read body [id=%s]: %w", id, err))
+		return
+	}
+	err = h.Registry.Save(id, content)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.lint(w, r)
+}
+
+func (h *Handler) lint(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	et, err := h.Registry.Open(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer et.File.Close()
+	writeJSON(w, http.StatusOK, lintResponse{ID: id, Issues: et.Lint()})
+}
+
+func (h *Handler) schema(w http.ResponseWriter, r *http.Request) {
+	compiled, err := h.Registry.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	schema, err := compiled.Schema()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schema)
+}
+
+func (h *Handler) render(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	compiled, err := h.Registry.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var data any
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, h.MaxBodyBytes)).Decode(&data)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			err = fmt.Errorf("%w: %v", errBadRequest, err)
+		}
+		writeError(w, fmt.Errorf("render: failed to decode body [id=%s]: %w", id, err))
+		return
+	}
+	// 填充数据必须是对象，列表中不是对象的元素在渲染时跳过
+	if _, ok := data.(map[string]any); !ok {
+		writeError(w, fmt.Errorf("render: %w: body must be a JSON object [id=%s]", errBadRequest, id))
+		return
+	}
+	if rows := countRows(data); h.MaxRows > 0 && rows > h.MaxRows {
+		writeError(w, fmt.Errorf("render: %w [id=%s, rows=%d, max=%d]", errTooManyRows, id, rows, h.MaxRows))
+		return
+	}
+
+	f, err := compiled.RenderContext(r.Context(), data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	// 直接写出到响应，不在内存中缓冲整个文件；开始写出后无法再返回错误状态码，写出失败时客户端收到不完整的响应
+	w.Header().Set("Content-Type", xlsxContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(id+templateExt)))
+	if err := f.Write(w); err != nil {
+		h.logf("render: failed to write response [id=%s]: %v", id, err)
+	}
+}
+
+// logf 输出错误日志
+func (h *Handler) logf(format string, args ...any) {
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// countRows 统计数据中所有数组的元素总数，包括嵌套的列表
+func countRows(data any) int {
+	switch v := data.(type) {
+	case []any:
+		count := len(v)
+		for _, item := range v {
+			count += countRows(item)
+		}
+		return count
+	case map[string]any:
+		count := 0
+		for _, item := range v {
+			count += countRows(item)
+		}
+		return count
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// newTemplateContent 创建只有一个列表区域的模板
+func newTemplateContent(t *testing.T, header string) []byte {
+	f := excelize.NewFile()
+	defer f.Close()
+	for i, row := range [][]any{{"表头", header}, {"数据", ""}, {"数据字段", "单号"}} {
+		cellName, _ := excelize.CoordinatesToCellName(1, i+1)
+		f.SetSheetRow("Sheet1", cellName, &row)
+	}
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "orders.xlsx"), newTemplateContent(t, "单号"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(NewRegistry(dir))
+	handler.MaxRows = 2
+	do := func(method string, target string, body []byte) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, bytes.NewReader(body)))
+		return recorder
+	}
+	headerOf := func(recorder *httptest.ResponseRecorder) string {
+		f, err := excelize.OpenReader(recorder.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, _ := f.GetCellValue("Sheet1", "A1")
+		return value
+	}
+
+	recorder := do(http.MethodPost, "/templates/orders/render", []byte(`{"table":[{"单号":"A1"},{"单号":"A2"}]}`))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != xlsxContentType {
+		t.Fatalf("渲染失败: %d %s", recorder.Code, recorder.Body)
+	}
+	if header := headerOf(recorder); header != "单号" {
+		t.Errorf("表头不正确: %s", header)
+	}
+
+	for target, status := range map[string]int{
+		"/templates/missing/render": http.StatusNotFound,
+		"/templates/..%2Fx/render":  http.StatusBadRequest,
+	} {
+		if recorder := do(http.MethodPost, target, []byte(`{}`)); recorder.Code != status {
+			t.Errorf("%s 期望 %d，实际 %d", target, status, recorder.Code)
+		}
+	}
+	// 列表中不是对象的元素跳过，请求体不是对象返回 400
+	for _, body := range []string{`{"table":[1]}`, `{"table":[null]}`} {
+		if recorder := do(http.MethodPost, "/templates/orders/render", []byte(body)); recorder.Code != http.StatusOK {
+			t.Errorf("%s 期望 200，实际 %d %s", body, recorder.Code, recorder.Body)
+		}
+	}
+	for _, body := range []string{`[1]`, `null`, `"x"`} {
+		if recorder := do(http.MethodPost, "/templates/orders/render", []byte(body)); recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s 期望 400，实际 %d", body, recorder.Code)
+		}
+	}
+	if recorder := do(http.MethodPost, "/templates/orders/render", []byte(`{"table":[{},{},{}]}`)); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超过行数限制应返回 413，实际 %d", recorder.Code)
+	}
+	handler.MaxBodyBytes = 8
+	if recorder := do(http.MethodPost, "/templates/orders/render", []byte(`{"table":[]}`)); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超过请求体限制应返回 413，实际 %d", recorder.Code)
+	}
+	handler.MaxBodyBytes = DefaultMaxBodyBytes
+
+	// 默认不允许上传
+	if recorder := do(http.MethodPut, "/templates/orders", newTemplateContent(t, "订单号")); recorder.Code != http.StatusForbidden {
+		t.Errorf("未开启上传应返回 403，实际 %d", recorder.Code)
+	}
+	handler.AllowUpload = true
+
+	// 上传后重新编译
+	time.Sleep(10 * time.Millisecond)
+	recorder = do(http.MethodPut, "/templates/orders", newTemplateContent(t, "订单号"))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"issues":[]`) {
+		t.Fatalf("上传失败: %d %s", recorder.Code, recorder.Body)
+	}
+	recorder = do(http.MethodPost, "/templates/orders/render", []byte(`{"table":[]}`))
+	if header := headerOf(recorder); header != "订单号" {
+		t.Errorf("模板修改后应重新编译，表头: %s", header)
+	}
+	if recorder := do(http.MethodPut, "/templates/bad", []byte("not xlsx")); recorder.Code != http.StatusBadRequest {
+		t.Errorf("上传非 xlsx 文件应返回 400，实际 %d", recorder.Code)
+	}
+
+	recorder = do(http.MethodGet, "/templates", nil)
+	var list struct{ Templates []string }
+	json.Unmarshal(recorder.Body.Bytes(), &list)
+	if len(list.Templates) != 1 || list.Templates[0] != "orders" {
+		t.Errorf("模板列表不正确: %s", recorder.Body)
+	}
+	recorder = do(http.MethodGet, "/templates/orders/schema", nil)
+	if !strings.Contains(recorder.Body.String(), `"单号"`) {
+		t.Errorf("数据结构不正确: %s", recorder.Body)
+	}
+}
+
+// failingWriter 写出响应体时总是失败
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestHandlerWriteError(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "orders.xlsx"), newTemplateContent(t, "单号"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	handler := NewHandler(NewRegistry(dir))
+	handler.ErrorLog = log.New(&logs, "", 0)
+	request := httptest.NewRequest(http.MethodPost, "/templates/orders/render", strings.NewReader(`{"table":[]}`))
+	handler.ServeHTTP(failingWriter{httptest.NewRecorder()}, request)
+	if !strings.Contains(logs.String(), "failed to write response [id=orders]: connection reset") {
+		t.Errorf("写出失败应记录日志，实际 %q", logs.String())
+	}
+}
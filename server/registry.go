@@ -0,0 +1,186 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	excel_template "github.com/mzzya/excel_template"
+)
+
+// templateExt 模板文件扩展名，模板 ID 为去掉扩展名的文件名
+const templateExt = ".xlsx"
+
+// ErrTemplateNotFound 模板不存在
+var ErrTemplateNotFound = errors.New("template not found")
+
+// ErrInvalidTemplateID 模板 ID 不合法
+var ErrInvalidTemplateID = errors.New("invalid template id")
+
+// ErrInvalidTemplate 上传的内容不是 xlsx 文件
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// templateIDRegexp 模板 ID 只能包含字母、数字、下划线、横线和点，不能包含路径
+var templateIDRegexp = regexp.MustCompile(`^[\p{L}\p{N}_-][\p{L}\p{N}_.-]*$`)
+
+// Registry 从目录中加载模板，每次获取时检查文件的修改时间和大小，变化后重新编译
+type Registry struct {
+	Dir string
+	// 渲染和检查模板时使用的函数、语言、默认列表字段、缺少字段的处理方式和时区
+	FuncMap       template.FuncMap
+	Language      string
+	ListField     string
+	MissingFields excel_template.MissingFieldPolicy
+	TimeZone      *time.Location
+
+	mutex   sync.Mutex
+	entries map[string]*registryEntry
+}
+
+// registryEntry 已编译的模板及编译时的文件状态
+type registryEntry struct {
+	modTime  time.Time
+	size     int64
+	compiled *excel_template.CompiledTemplate
+}
+
+// NewRegistry 创建从 dir 加载模板的 Registry
+func NewRegistry(dir string) *Registry {
+	return &Registry{Dir: dir, entries: make(map[string]*registryEntry)}
+}
+
+// path 返回模板 ID 对应的文件路径
+func (r *Registry) path(id string) (string, error) {
+	if !templateIDRegexp.MatchString(id) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("%w [id=%s]", ErrInvalidTemplateID, id)
+	}
+	return filepath.Join(r.Dir, id+templateExt), nil
+}
+
+// List 返回目录中所有模板的 ID
+func (r *Registry) List() ([]string, error) {
+	entries, err := os.ReadDir(r.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("List: failed to read dir [dir=%s]: %w", r.Dir, err)
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		// 跳过 Excel 打开文件时生成的锁文件
+		if entry.IsDir() || !strings.HasSuffix(name, templateExt) || strings.HasPrefix(name, "~$") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, templateExt))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Get 返回编译好的模板，文件在上次编译后被修改时重新编译。
+// 编译时不持有锁，编译较慢的模板不会阻塞其他模板；同一模板同时被请求时可能重复编译，结果相同
+func (r *Registry) Get(id string) (*excel_template.CompiledTemplate, error) {
+	path, err := r.path(id)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w [id=%s]", ErrTemplateNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Get: failed to stat template [id=%s]: %w", id, err)
+	}
+
+	r.mutex.Lock()
+	entry, ok := r.entries[id]
+	r.mutex.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.compiled, nil
+	}
+	et, err := r.open(id, path)
+	if err != nil {
+		return nil, err
+	}
+	defer et.File.Close()
+	compiled, err := et.Compile()
+	if err != nil {
+		return nil, fmt.Errorf("Get: failed to compile template [id=%s]: %w", id, err)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.entries == nil {
+		r.entries = make(map[string]*registryEntry)
+	}
+	r.entries[id] = &registryEntry{modTime: info.ModTime(), size: info.Size(), compiled: compiled}
+	return compiled, nil
+}
+
+// Open 打开模板文件，用于检查等需要原始模板的场景，调用方负责关闭 File
+func (r *Registry) Open(id string) (*excel_template.ExcelTemplate, error) {
+	path, err := r.path(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w [id=%s]", ErrTemplateNotFound, id)
+	}
+	return r.open(id, path)
+}
+
+func (r *Registry) open(id string, path string) (*excel_template.ExcelTemplate, error) {
+	et, err := excel_template.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Open: failed to open template [id=%s]: %w", id, err)
+	}
+	et.FuncMap = r.FuncMap
+	et.Language = r.Language
+	et.MissingFields = r.MissingFields
+	et.TimeZone = r.TimeZone
+	if r.ListField != "" {
+		et.ListField = r.ListField
+	}
+	return et, nil
+}
+
+// Save 校验并保存模板，先写入临时文件再替换，正在进行的渲染不受影响
+func (r *Registry) Save(id string, content []byte) error {
+	path, err := r.path(id)
+	if err != nil {
+		return err
+	}
+	et, err := excel_template.OpenBytes(content)
+	if err != nil {
+		return fmt.Errorf("Save: %w [id=%s]: %v", ErrInvalidTemplate, id, err)
+	}
+	et.File.Close()
+
+	file, err := os.CreateTemp(r.Dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("Save: failed to create temp file [id=%s]: %w", id, err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Save: failed to write template [id=%s]: %w", id, err)
+	}
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		return fmt.Errorf("Save: failed to replace template [id=%s]: %w", id, err)
+	}
+	// 修改时间精度不足时文件状态可能不变，直接丢弃已编译的模板
+	r.mutex.Lock()
+	delete(r.entries, id)
+	r.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,443 @@
+package excel_template
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/xuri/excelize/v2"
+)
+
+// streamBlock 流式写出的列表区域，offset 为该区域插入的行数
+type streamBlock struct {
+	*TableBlock
+	list []map[string]any
+	// 写出后数据的起始行，包含上方列表区域插入的行数
+	fillRowNum int
+	offset     int
+}
+
+// streamSheet 计算各列表区域的数据和写出位置后，使用 StreamWriter 写出整个工作表
+func (et *ExcelTemplate) streamSheet(ctx context.Context, sheet string) error {
+	blocks := make([]*streamBlock, 0, len(et.SheetCache[sheet].Blocks))
+	// 从上往下计算，上方区域插入的行数决定下方区域写出后的位置
+	offset := 0
+	for _, block := range et.SheetCache[sheet].Blocks {
+		list := et.getBlockList(block, et.SheetCache[sheet].FillData)
+		if len(list) == 0 {
+			continue
+		}
+		fillRowNum := block.StartRowNum + offset
+		// 处理分类汇总
+		list = et.handleSubtotal(et.SheetCache[sheet].Keywords, block, list, fillRowNum)
+		sb := &streamBlock{
+			TableBlock: block,
+			list:       list,
+			fillRowNum: fillRowNum,
+			offset:     max(len(list)-block.TemplateDataRows, 0),
+		}
+		offset += sb.offset
+		blocks = append(blocks, sb)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	et.startProgress(sheet, lo.SumBy(blocks, func(block *streamBlock) int { return len(block.list) }))
+
+	// StreamWriter 在 Flush 时整体替换工作表，筛选和页面设置需要在写出之前完成
+	if blocks[0].TableBlock == et.SheetCache[sheet].Blocks[0] {
+		et.setAutoFilter(sheet, blocks[0].TableBlock, len(blocks[0].list))
+	}
+	et.File.SetSheetProps(sheet, et.SheetPropsOptions)
+	et.File.SetPageLayout(sheet, et.PageLayoutOptions)
+	err := et.streamData(ctx, sheet, blocks)
+	if err != nil {
+		return fmt.Errorf("streamSheet: failed to stream data [sheet=%s]: %w", sheet, err)
+	}
+	return nil
+}
+
+// streamData 使用 StreamWriter 按行写出整个工作表，数据行不再经过 InsertRows，
+// 工作表内容会分批刷入临时文件，内存占用不随数据行数增长。
+// 行的偏移规则与非流式模式的 InsertRows 一致，模板行中的公式、合并单元格和图片会同步下移。
+// 注意：StreamWriter 在 Flush 时整体替换工作表，调用前需完成筛选、页面设置等工作表级别的操作
+func (et *ExcelTemplate) streamData(ctx context.Context, sheet string, blocks []*streamBlock) error {
+	maxRow, maxCol, err := et.getSheetBounds(sheet)
+	if err != nil {
+		return fmt.Errorf("streamData: failed to get sheet bounds [sheet=%s]: %w", sheet, err)
+	}
+	for _, block := range blocks {
+		for _, column := range block.ColumnList {
+			maxCol = max(maxCol, column.RenderColNum)
+		}
+		// 空白的模板数据行位于工作表末尾时不在 getSheetBounds 的范围内，需要补上，否则数据行不会写出
+		maxRow = max(maxRow, block.StartRowNum+block.TemplateDataRows-1)
+	}
+
+	mergeCells, err := et.File.GetMergeCells(sheet)
+	if err != nil {
+		return fmt.Errorf("streamData: failed to get merge cells [sheet=%s]: %w", sheet, err)
+	}
+	mergeRanges := parseMergeCells(mergeCells)
+	// 模板中保留的数据行由数据重新生成
+	isDataRow := func(rowNum int) bool {
+		return lo.ContainsBy(blocks, func(block *streamBlock) bool {
+			return rowNum >= block.StartRowNum && rowNum < block.StartRowNum+min(len(block.list), block.TemplateDataRows)
+		})
+	}
+
+	// 读取模板行
+	templateRows := make(map[int][]any, maxRow)
+	rowOpts := make(map[int]excelize.RowOpts, maxRow)
+	for rowNum := 1; rowNum <= maxRow; rowNum++ {
+		if isDataRow(rowNum) {
+			continue
+		}
+		cells, opts, err := et.readTemplateRow(sheet, rowNum, maxCol, mergeRanges, blocks)
+		if err != nil {
+			return fmt.Errorf("streamData: failed to read template row [sheet=%s, row=%d]: %w", sheet, rowNum, err)
+		}
+		templateRows[rowNum] = cells
+		rowOpts[rowNum] = opts
+	}
+
+	// 从下往上移动，与 InsertRows 的执行顺序一致
+	for i := len(blocks) - 1; i >= 0; i-- {
+		err = et.shiftPictures(sheet, blocks[i].StartRowNum+1, blocks[i].offset)
+		if err != nil {
+			return fmt.Errorf("streamData: failed to shift pictures [sheet=%s]: %w", sheet, err)
+		}
+	}
+
+	for _, block := range blocks {
+		err = et.setConditionalColors(sheet, block.TableBlock, block.fillRowNum, block.list)
+		if err != nil {
+			return fmt.Errorf("streamData: %w", err)
+		}
+	}
+	// 流式模式下条件格式写入时已是最终位置
+	delete(et.conditionalColors, sheet)
+
+	sw, err := et.File.NewStreamWriter(sheet)
+	if err != nil {
+		return fmt.Errorf("streamData: failed to create stream writer [sheet=%s]: %w", sheet, err)
+	}
+	// 列宽需要在写出第一行之前设置，StreamWriter 设置的列宽在 Flush 之前无法通过 GetColWidth 读取，计算行高时直接使用
+	widths := make(map[int]float64)
+	for _, block := range blocks {
+		for col, width := range et.blockColumnWidths(sheet, block.TableBlock, block.list, block.fillRowNum) {
+			widths[col] = width
+		}
+	}
+	for col, width := range widths {
+		err = sw.SetColWidth(col, col, width)
+		if err != nil {
+			return fmt.Errorf("streamData: failed to set column width [sheet=%s, col=%d]: %w", sheet, col, err)
+		}
+	}
+
+	for _, mergeRange := range mergeRanges {
+		// 数据行上的合并单元格由 streamDataRow 逐行生成
+		if isDataRow(mergeRange.StartRow) && isDataRow(mergeRange.EndRow) {
+			continue
+		}
+		topLeftCell, _ := excelize.CoordinatesToCellName(mergeRange.StartCol, shiftRow(mergeRange.StartRow, blocks))
+		bottomRightCell, _ := excelize.CoordinatesToCellName(mergeRange.EndCol, shiftRow(mergeRange.EndRow, blocks))
+		err = sw.MergeCell(topLeftCell, bottomRightCell)
+		if err != nil {
+			return fmt.Errorf("streamData: failed to merge [sheet=%s, cell=%s:%s]: %w", sheet, topLeftCell, bottomRightCell, err)
+		}
+	}
+
+	for rowNum := 1; rowNum <= maxRow; rowNum++ {
+		for _, block := range blocks {
+			if rowNum != block.StartRowNum {
+				continue
+			}
+			for i := range block.list {
+				err = et.checkRowProgress(ctx, sheet, i)
+				if err != nil {
+					return fmt.Errorf("streamData: %w", err)
+				}
+				err = et.streamDataRow(sw, sheet, block, i, block.list[i], maxCol, widths)
+				if err != nil {
+					return fmt.Errorf("streamData: failed to write data row [sheet=%s, row=%d]: %w", sheet, i, err)
+				}
+			}
+		}
+		cells, ok := templateRows[rowNum]
+		if !ok {
+			continue
+		}
+		targetRow := shiftRow(rowNum, blocks)
+		err = sw.SetRow(fmt.Sprintf("A%d", targetRow), cells, rowOpts[rowNum])
+		if err != nil {
+			return fmt.Errorf("streamData: failed to write template row [sheet=%s, row=%d]: %w", sheet, targetRow, err)
+		}
+	}
+
+	err = sw.Flush()
+	if err != nil {
+		return fmt.Errorf("streamData: failed to flush stream writer [sheet=%s]: %w", sheet, err)
+	}
+	et.reportProgress()
+	return nil
+}
+
+// shiftRow 计算模板行写出后的行号，累加位于该行上方的列表区域插入的行数
+func shiftRow(rowNum int, blocks []*streamBlock) int {
+	targetRow := rowNum
+	for _, block := range blocks {
+		if rowNum >= block.StartRowNum+1 {
+			targetRow += block.offset
+		}
+	}
+	return targetRow
+}
+
+// streamDataRow 计算一行数据的值、公式和样式，并通过 StreamWriter 写出
+func (et *ExcelTemplate) streamDataRow(sw *excelize.StreamWriter, sheet string, block *streamBlock, listIndex int, rowData map[string]any, maxCol int, widths map[int]float64) error {
+	rowNum := block.fillRowNum + listIndex
+	formulaResultCache := make(map[string]any)
+	styleIdCache := make(map[string]int)
+	isSubtotal := rowData["_row_type"] == "subtotal"
+	_listIndex := listIndex
+	if _, ok := rowData["_row_index"]; ok {
+		_listIndex = rowData["_row_index"].(int)
+	}
+	if !isSubtotal {
+		rowData = et.fillDefaults(rowData, block.Defaults)
+	}
+
+	cells := make([]any, maxCol)
+	// 自动换行的单元格显示全部文字需要的行高
+	fitHeight := 0.0
+	for _, column := range block.ColumnList {
+		cellName := fmt.Sprintf("%s%d", column.RenderColName, rowNum)
+		cellData, cellFormulaCache := column.cellData(rowData, formulaResultCache)
+		value, formula, err := et.resolveCellData(sheet, cellName, column, _listIndex, rowNum, cellData, isSubtotal)
+		if err = et.handleCellError(locateRenderError(err, sheet, cellName, listIndex)); err != nil {
+			return fmt.Errorf("streamDataRow: failed to resolve cell value [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+		}
+		// 合并列只创建一次合并区域
+		isMergedCell := column.IsMergeCell && column.ColNum != column.MergeRange.StartCol
+		if column.IsMergeCell && !isMergedCell {
+			topLeftCell, _ := excelize.CoordinatesToCellName(column.MergeRange.StartCol-1, rowNum)
+			bottomRightCell, _ := excelize.CoordinatesToCellName(column.MergeRange.EndCol-1, rowNum)
+			err = sw.MergeCell(topLeftCell, bottomRightCell)
+			if err != nil {
+				return fmt.Errorf("streamDataRow: failed to merge [sheet=%s, cell=%s:%s]: %w", sheet, topLeftCell, bottomRightCell, err)
+			}
+		}
+		//图片不属于单元格内容，直接添加到工作表的绘图中
+		if strValue, ok := value.(string); ok && IsBase64Image(strValue) {
+			err = et.setCellData(sheet, cellName, strValue)
+			if err = et.handleCellError(locateRenderError(err, sheet, cellName, listIndex)); err != nil {
+				return fmt.Errorf("streamDataRow: failed to add picture [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+			}
+			value = nil
+		}
+		// 与非流式模式一致：合并区域中其他列的内容写入左上角单元格，靠后的列覆盖靠前的列，
+		// 分类汇总行中没有内容的列不覆盖
+		if isMergedCell {
+			if !isSubtotal || value != nil || formula != "" {
+				topLeftIndex := column.MergeRange.StartCol - 2
+				if topLeft, ok := cells[topLeftIndex].(excelize.Cell); ok {
+					topLeft.Value, topLeft.Formula = value, formula
+					cells[topLeftIndex] = topLeft
+				}
+			}
+			value, formula = nil, ""
+		}
+		styleId := 0
+		if !isSubtotal {
+			styleId, err = et.resolveCellStyle(sheet, cellFormulaCache, styleIdCache, cellName, column, _listIndex, cellData, value)
+			if err = et.handleCellError(locateRenderError(err, sheet, cellName, listIndex)); err != nil {
+				return fmt.Errorf("streamDataRow: failed to resolve cell style [sheet=%s, cell=%s]: %w", sheet, cellName, err)
+			}
+		}
+		cells[column.RenderColNum-1] = excelize.Cell{StyleID: styleId, Value: value, Formula: formula}
+		if !isSubtotal {
+			fitHeight = max(fitHeight, et.wrapTextHeight(sheet, column, _listIndex, value, widths))
+		}
+	}
+	height := block.DataRowHeight
+	if fitHeight > 0 {
+		height = et.fitRowHeight(height, fitHeight)
+	}
+	return sw.SetRow(fmt.Sprintf("A%d", rowNum), cells, excelize.RowOpts{Height: height})
+}
+
+// readTemplateRow 读取模板行的单元格和行属性，公式中的行号按插入行的规则偏移
+func (et *ExcelTemplate) readTemplateRow(sheet string, rowNum int, maxCol int, mergeRanges []MergeRange, blocks []*streamBlock) ([]any, excelize.RowOpts, error) {
+	opts := excelize.RowOpts{}
+	height, err := et.File.GetRowHeight(sheet, rowNum)
+	if err != nil {
+		return nil, opts, err
+	}
+	visible, err := et.File.GetRowVisible(sheet, rowNum)
+	if err != nil {
+		return nil, opts, err
+	}
+	// 与默认行高相同时不写入，保持行高跟随工作表设置
+	props, err := et.File.GetSheetProps(sheet)
+	if err != nil {
+		return nil, opts, err
+	}
+	if props.DefaultRowHeight == nil || height != *props.DefaultRowHeight {
+		opts.Height = height
+	}
+	opts.Hidden = !visible
+
+	cells := make([]any, maxCol)
+	for colNum := 1; colNum <= maxCol; colNum++ {
+		cellName, err := excelize.CoordinatesToCellName(colNum, rowNum)
+		if err != nil {
+			return nil, opts, err
+		}
+		cell, err := et.readTemplateCell(sheet, cellName)
+		if err != nil {
+			return nil, opts, err
+		}
+		if cell == nil {
+			continue
+		}
+		// GetCellValue 和 GetCellFormula 对合并区域内的单元格都返回左上角的内容，只保留左上角单元格的内容
+		if lo.ContainsBy(mergeRanges, func(m MergeRange) bool {
+			return colNum >= m.StartCol && colNum <= m.EndCol && rowNum >= m.StartRow && rowNum <= m.EndRow && (colNum != m.StartCol || rowNum != m.StartRow)
+		}) {
+			cell.Value = nil
+			cell.Formula = ""
+		}
+		// 从下往上依次偏移，与 InsertRows 的执行顺序一致
+		for i := len(blocks) - 1; i >= 0 && cell.Formula != ""; i-- {
+			cell.Formula = ShiftFormulaRows(cell.Formula, blocks[i].StartRowNum+1, blocks[i].offset)
+		}
+		cells[colNum-1] = *cell
+	}
+	return cells, opts, nil
+}
+
+// readTemplateCell 读取模板单元格的值、公式和样式，数字和布尔值保留原始类型
+func (et *ExcelTemplate) readTemplateCell(sheet, cellName string) (*excelize.Cell, error) {
+	formula, err := et.File.GetCellFormula(sheet, cellName)
+	if err != nil {
+		return nil, err
+	}
+	styleId, err := et.File.GetCellStyle(sheet, cellName)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := et.File.GetCellValue(sheet, cellName, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, err
+	}
+	if formula == "" && styleId == 0 && raw == "" {
+		return nil, nil
+	}
+	cell := &excelize.Cell{StyleID: styleId, Formula: formula}
+	if formula != "" || raw == "" {
+		return cell, nil
+	}
+	cellType, err := et.File.GetCellType(sheet, cellName)
+	if err != nil {
+		return nil, err
+	}
+	switch cellType {
+	case excelize.CellTypeUnset, excelize.CellTypeNumber:
+		if number, err := strconv.ParseFloat(raw, 64); err == nil {
+			cell.Value = number
+		} else {
+			cell.Value = raw
+		}
+	case excelize.CellTypeBool:
+		cell.Value = raw == "1" || raw == "TRUE"
+	default:
+		cell.Value = raw
+	}
+	return cell, nil
+}
+
+// getSheetBounds 获取工作表已使用区域的最大行号和最大列号
+func (et *ExcelTemplate) getSheetBounds(sheet string) (int, int, error) {
+	rows, err := et.File.GetRows(sheet)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxRow, maxCol := len(rows), 0
+	for _, row := range rows {
+		maxCol = max(maxCol, len(row))
+	}
+	dimension, err := et.File.GetSheetDimension(sheet)
+	if err != nil {
+		return 0, 0, err
+	}
+	if coords := strings.Split(dimension, ":"); len(coords) == 2 {
+		if col, row, err := excelize.CellNameToCoordinates(coords[1]); err == nil {
+			maxCol = max(maxCol, col)
+			maxRow = max(maxRow, row)
+		}
+	}
+	mergeCells, err := et.File.GetMergeCells(sheet)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, mergeRange := range parseMergeCells(mergeCells) {
+		maxCol = max(maxCol, mergeRange.EndCol)
+		maxRow = max(maxRow, mergeRange.EndRow)
+	}
+	return maxRow, maxCol, nil
+}
+
+// shiftPictures 将 fromRow 及其后锚定的图片下移 offset 行
+func (et *ExcelTemplate) shiftPictures(sheet string, fromRow int, offset int) error {
+	if offset == 0 {
+		return nil
+	}
+	cells, err := et.File.GetPictureCells(sheet)
+	if err != nil {
+		return err
+	}
+	type pictureCell struct {
+		col, row int
+		name     string
+	}
+	pictureCells := make([]pictureCell, 0, len(cells))
+	for _, cellName := range cells {
+		col, row, err := excelize.CellNameToCoordinates(cellName)
+		if err != nil {
+			return err
+		}
+		if row >= fromRow {
+			pictureCells = append(pictureCells, pictureCell{col: col, row: row, name: cellName})
+		}
+	}
+	// 从下往上移动，避免目标单元格与尚未移动的图片重叠
+	sort.Slice(pictureCells, func(i, j int) bool {
+		return pictureCells[i].row > pictureCells[j].row
+	})
+	for _, pc := range pictureCells {
+		pictures, err := et.File.GetPictures(sheet, pc.name)
+		if err != nil {
+			return err
+		}
+		err = et.File.DeletePicture(sheet, pc.name)
+		if err != nil {
+			return err
+		}
+		targetCell, err := excelize.CoordinatesToCellName(pc.col, pc.row+offset)
+		if err != nil {
+			return err
+		}
+		for _, picture := range pictures {
+			err = et.File.AddPictureFromBytes(sheet, targetCell, &picture)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
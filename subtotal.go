@@ -1,7 +1,12 @@
 package excel_template
 
-import "fmt"
+import (
+	"fmt"
 
+	"github.com/mzzya/excel_template/constant"
+)
+
+// Subtotal 分类汇总函数，Func 为模板中汇总函数的文字，GroupFieldSuffix 为分组汇总行的后缀，TotalField 为总计行的文字
 type Subtotal struct {
 	Code             int
 	Func             string
@@ -9,6 +14,16 @@ type Subtotal struct {
 	TotalField       string
 }
 
+// subtotalCodes 汇总函数关键字名称对应的 SUBTOTAL 函数编号
+var subtotalCodes = map[string]int{
+	constant.KeySum:     9,
+	constant.KeyCount:   3,
+	constant.KeyAverage: 1,
+	constant.KeyMax:     4,
+	constant.KeyMin:     5,
+}
+
+// Subtotals 默认语言的分类汇总函数，其他语言的文字由模板的语言包决定
 var Subtotals = []Subtotal{
 	{Code: 9, Func: "求和", GroupFieldSuffix: "汇总", TotalField: "总计"},
 	{Code: 3, Func: "计数", GroupFieldSuffix: "计数", TotalField: "总计数"},
@@ -17,6 +32,20 @@ var Subtotals = []Subtotal{
 	{Code: 5, Func: "最小值", GroupFieldSuffix: "最小值", TotalField: "总计最小值"},
 }
 
+// subtotalOf 按关键字名称（如 constant.KeySum）查找汇总函数，使用 keywords 中的文字填充函数、分组汇总行后缀和总计行文字
+func subtotalOf(keywords constant.Keywords, name string) (Subtotal, bool) {
+	code, ok := subtotalCodes[name]
+	if !ok {
+		return Subtotal{}, false
+	}
+	return Subtotal{
+		Code:             code,
+		Func:             keywords[name],
+		GroupFieldSuffix: keywords[name+constant.KeySuffix],
+		TotalField:       keywords[name+constant.KeyTotal],
+	}, true
+}
+
 func GroupAndSubtotal(data []map[string]any, groupField string, sumField string, subtotalCellLetter string, dataStartRow int, subtotal Subtotal) []map[string]any {
 	if len(data) == 0 {
 		return nil
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"regexp"
 	"text/template"
+	"text/template/parse"
 )
 
 func ContainsGoTemplateSyntax(s string) bool {
@@ -27,3 +28,15 @@ func RenderTemplate(tmplStr string, data any, funcMap template.FuncMap) (string,
 
 	return buf.String(), nil
 }
+
+// CheckTemplateSyntax 检查模板语法，funcMap 为 nil 时不检查函数是否存在
+func CheckTemplateSyntax(tmplStr string, funcMap template.FuncMap) error {
+	if funcMap != nil {
+		_, err := template.New("template").Funcs(funcMap).Parse(tmplStr)
+		return err
+	}
+	tree := parse.New("template")
+	tree.Mode = parse.SkipFuncCheck
+	_, err := tree.Parse(tmplStr, "", "", map[string]*parse.Tree{})
+	return err
+}
@@ -0,0 +1,220 @@
+package excel_template
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// columnWidthAuto 列宽配置行中按内容计算列宽的值
+const columnWidthAuto = "auto"
+
+// columnWidthPadding 按内容计算列宽时在最长文字之外留出的宽度
+const columnWidthPadding = 2
+
+// 估算文字尺寸时使用的默认字号和该字号的单行行高（磅），列宽的单位是默认字号下的字符数
+const (
+	defaultFontSize   = 11
+	defaultLineHeight = 15
+)
+
+// AutoFitOptions 按内容调整列宽和行高的范围，为 0 的项不限制，列宽和行高不会超过 Excel 的上限
+type AutoFitOptions struct {
+	// 列宽（字符数）的下限和上限
+	MinColumnWidth float64
+	MaxColumnWidth float64
+	// 有自动换行单元格的数据行行高（磅）的下限和上限
+	MinRowHeight float64
+	MaxRowHeight float64
+}
+
+// parseColumnWidth 解析列宽配置行中的值，返回固定列宽和是否按内容计算列宽，空值表示保持模板中的列宽
+func parseColumnWidth(value string) (float64, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false, nil
+	}
+	if strings.EqualFold(value, columnWidthAuto) {
+		return 0, true, nil
+	}
+	width, err := strconv.ParseFloat(value, 64)
+	if err != nil || width <= 0 || width > excelize.MaxColumnWidth {
+		return 0, false, fmt.Errorf("column width must be %q or a number between 0 and %d: %q", columnWidthAuto, excelize.MaxColumnWidth, value)
+	}
+	return width, false, nil
+}
+
+// blockColumnWidths 计算列表区域中需要修改的列宽，键为渲染后的列号。
+// 配置为 auto 的列和设置了 AutoFit 时未配置固定列宽的列，按表头和各行数据的文字以及数据行的字号估算，
+// 流式模式写出数据前也能得到同样的结果
+func (et *ExcelTemplate) blockColumnWidths(sheet string, block *TableBlock, list []map[string]any, fillRowNum int) map[int]float64 {
+	widths := make(map[int]float64)
+	autoColumns := make([]*Column, 0)
+	for _, column := range block.ColumnList {
+		if column.Width > 0 {
+			widths[column.RenderColNum] = column.Width
+		} else if column.AutoWidth || et.AutoFit != nil {
+			autoColumns = append(autoColumns, column)
+			widths[column.RenderColNum] = textWidth(column.Header) * fontScale(column.dataStyle(0))
+		}
+	}
+	if len(autoColumns) == 0 {
+		return widths
+	}
+	for i, rowData := range list {
+		isSubtotal := rowData["_row_type"] == "subtotal"
+		listIndex := i
+		if index, ok := rowData["_row_index"].(int); ok {
+			listIndex = index
+		}
+		if !isSubtotal {
+			rowData = et.fillDefaults(rowData, block.Defaults)
+		}
+		for _, column := range autoColumns {
+			cellData, _ := column.cellData(rowData, nil)
+			// 渲染失败的单元格由渲染过程处理，这里只跳过
+			value, _, err := et.resolveCellData(sheet, "", column, listIndex, fillRowNum+i, cellData, isSubtotal)
+			if err != nil {
+				continue
+			}
+			widths[column.RenderColNum] = max(widths[column.RenderColNum], textWidth(cellText(value))*fontScale(column.dataStyle(listIndex)))
+		}
+	}
+	for _, column := range autoColumns {
+		widths[column.RenderColNum] = et.fitColumnWidth(widths[column.RenderColNum] + columnWidthPadding)
+	}
+	return widths
+}
+
+// fitColumnWidth 将按内容计算的列宽限制在 AutoFit 的范围内
+func (et *ExcelTemplate) fitColumnWidth(width float64) float64 {
+	if et.AutoFit != nil {
+		if et.AutoFit.MinColumnWidth > 0 {
+			width = max(width, et.AutoFit.MinColumnWidth)
+		}
+		if et.AutoFit.MaxColumnWidth > 0 {
+			width = min(width, et.AutoFit.MaxColumnWidth)
+		}
+	}
+	return min(width, excelize.MaxColumnWidth)
+}
+
+// wrapTextHeight 返回自动换行的单元格按列宽显示全部文字需要的行高，列宽优先使用 widths 中修改后的列宽，
+// 未设置 AutoFit 或单元格不自动换行时返回 0
+func (et *ExcelTemplate) wrapTextHeight(sheet string, column *Column, listIndex int, value any, widths map[int]float64) float64 {
+	if et.AutoFit == nil {
+		return 0
+	}
+	style := column.dataStyle(listIndex)
+	if style == nil || style.Alignment == nil || !style.Alignment.WrapText {
+		return 0
+	}
+	text := cellText(value)
+	if text == "" {
+		return 0
+	}
+	// 合并单元格按合并区域的总宽度换行
+	startCol, endCol := column.RenderColNum, column.RenderColNum
+	if column.IsMergeCell {
+		startCol, endCol = column.MergeRange.StartCol-1, column.MergeRange.EndCol-1
+	}
+	colWidth := 0.0
+	for col := startCol; col <= endCol; col++ {
+		if width, ok := widths[col]; ok {
+			colWidth += width
+			continue
+		}
+		colName, _ := excelize.ColumnNumberToName(col)
+		width, err := et.File.GetColWidth(sheet, colName)
+		if err != nil {
+			return 0
+		}
+		colWidth += width
+	}
+	if colWidth <= 0 {
+		return 0
+	}
+	scale := fontScale(style)
+	lines := 0
+	for _, line := range strings.Split(text, "\n") {
+		lines += max(1, int(math.Ceil(textWidth(line)*scale/colWidth)))
+	}
+	return float64(lines) * defaultLineHeight * scale
+}
+
+// fitRowHeight 返回有自动换行单元格的数据行的行高，不低于模板数据行的行高，并限制在 AutoFit 的范围内
+func (et *ExcelTemplate) fitRowHeight(rowHeight float64, fitHeight float64) float64 {
+	height := max(rowHeight, fitHeight)
+	if et.AutoFit.MinRowHeight > 0 {
+		height = max(height, et.AutoFit.MinRowHeight)
+	}
+	if et.AutoFit.MaxRowHeight > 0 {
+		height = min(height, et.AutoFit.MaxRowHeight)
+	}
+	return min(height, excelize.MaxRowHeight)
+}
+
+// dataStyle 返回数据行使用的模板单元格样式，列没有模板数据行时返回 nil
+func (column *Column) dataStyle(listIndex int) *excelize.Style {
+	if len(column.CellList) == 0 {
+		return nil
+	}
+	return column.CellList[listIndex%len(column.CellList)].Style
+}
+
+// fontScale 返回样式字号相对默认字号的比例，估算的文字宽度和行高按此缩放
+func fontScale(style *excelize.Style) float64 {
+	if style == nil || style.Font == nil || style.Font.Size <= 0 {
+		return 1
+	}
+	return style.Font.Size / defaultFontSize
+}
+
+// cellText 返回估算列宽时单元格中显示的文字，图片数据不占宽度
+func cellText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if IsBase64Image(v) {
+			return ""
+		}
+		return v
+	case time.Time:
+		if timeNumFmt(v) == 14 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// textWidth 估算文字的显示宽度（字符数），全角字符按 2 个字符计算，多行文字取最长的一行
+func textWidth(text string) float64 {
+	width := 0
+	for _, line := range strings.Split(text, "\n") {
+		lineWidth := 0
+		for _, r := range line {
+			if isWideRune(r) {
+				lineWidth += 2
+			} else {
+				lineWidth++
+			}
+		}
+		width = max(width, lineWidth)
+	}
+	return float64(width)
+}
+
+// isWideRune 是否为中日韩文字、全角标点等占两个字符宽度的字符
+func isWideRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6)
+}